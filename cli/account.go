@@ -1,16 +1,33 @@
 package cli
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
 )
 
 func (cli *CLI) buildAccountCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "account [new|list]",
+		Use:   "account [new|list|import|export|update|inspect|upgrade|hd]",
 		Short: "Manage NewChain accounts",
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
@@ -20,20 +37,47 @@ func (cli *CLI) buildAccountCmd() *cobra.Command {
 
 	cmd.AddCommand(cli.buildAccountNewCmd())
 	cmd.AddCommand(cli.buildAccountListCmd())
+	cmd.AddCommand(cli.buildAccountImportCmd())
+	cmd.AddCommand(cli.buildAccountExportCmd())
+	cmd.AddCommand(cli.buildAccountUpdateCmd())
+	cmd.AddCommand(cli.buildAccountInspectCmd())
+	cmd.AddCommand(cli.buildAccountUpgradeCmd())
+	cmd.AddCommand(cli.buildAccountHDCmd())
 
 	return cmd
 }
 
 func (cli *CLI) buildAccountNewCmd() *cobra.Command {
 	accountNewCmd := &cobra.Command{
-		Use:   "new [-n number] [--faucet]",
+		Use:   "new [-n number] [--faucet [-a amount] [-u unit] [--wait]]",
 		Short: "create a new account",
 		Args:  cobra.MinimumNArgs(0),
 		DisableFlagsInUseLine: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			fund, _ := cmd.Flags().GetBool("faucet")
+			var amountWei *big.Int
+			unit, ok := normalizeUnit(flagOrConfig(cmd, "unit", "faucet.unit"))
+			if fund {
+				if !ok {
+					fmt.Printf("Unit(%s) for amount error. %s.\n", unit, DenominationString)
+					fmt.Fprint(os.Stderr, cmd.UsageString())
+					return
+				}
+				var err error
+				amountWei, err = getAmountWei(flagOrConfig(cmd, "amount", "faucet.amount"), unit)
+				if err != nil {
+					fmt.Println("Get amount error:", err)
+					fmt.Fprint(os.Stderr, cmd.UsageString())
+					return
+				}
+				if amountWei.Sign() <= 0 {
+					fmt.Println("Error: amount must be greater than 0")
+					return
+				}
+			}
+
 			walletPath := cli.walletPath
-			wallet := keystore.NewKeyStore(walletPath,
-				keystore.LightScryptN, keystore.LightScryptP)
+			wallet := newKeyStore(walletPath)
 
 			walletPassword, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true)
 			if err != nil {
@@ -50,22 +94,108 @@ func (cli *CLI) buildAccountNewCmd() *cobra.Command {
 				numOfNew = 1
 			}
 
+			var addresses []common.Address
 			for i := 0; i < numOfNew; i++ {
 				account, err := wallet.NewAccount(walletPassword)
 				if err != nil {
 					fmt.Println("Account error:", err)
-					return
+					break
 				}
 
 				fmt.Println(account.Address.Hex())
+				addresses = append(addresses, account.Address)
+			}
+
+			if fund && len(addresses) > 0 {
+				wait, _ := cmd.Flags().GetBool("wait")
+				timeout, _ := cmd.Flags().GetDuration("timeout")
+				cli.fundAccounts(addresses, amountWei, wait, timeout)
 			}
 		},
 	}
 
 	accountNewCmd.Flags().IntP("numOfNew", "n", 1, "number of the new account")
+	accountNewCmd.Flags().Bool("faucet", false, "Send the amount to each new account from faucet.from")
+	accountNewCmd.Flags().StringP("amount", "a", "", "The `amount` to send to each account (default faucet.amount in the config file)")
+	accountNewCmd.Flags().StringP("unit", "u", "", fmt.Sprintf("The `unit` of the amount (default faucet.unit in the config file). %s.", DenominationString))
+	accountNewCmd.Flags().Bool("wait", false, "Wait for the receipts of the transactions")
+	accountNewCmd.Flags().Duration("timeout", 5*time.Minute, "The `duration` to wait for the receipts")
 	return accountNewCmd
 }
 
+// fundAccounts sends amountWei to each address from the faucet account and
+// prints the transactions, with their receipts if wait
+func (cli *CLI) fundAccounts(addresses []common.Address, amountWei *big.Int, wait bool, timeout time.Duration) {
+	client, err := ethclient.Dial(cli.rpcURL)
+	if err != nil {
+		fmt.Println("Dial error:", err)
+		return
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	cli.networkID, err = client.NetworkID(ctx)
+	if err != nil {
+		fmt.Println("Get NetworkID Error: ", err)
+		return
+	}
+	from, err := cli.unlockSender(viper.GetString("faucet.from"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	// do not replace the pending transactions of the account
+	cli.nonce, err = client.PendingNonceAt(ctx, from)
+	if err != nil {
+		fmt.Println("PendingNonceAt error:", err)
+		return
+	}
+
+	txs := make([]*types.Transaction, len(addresses))
+	statuses := make([]string, len(addresses))
+	for i, address := range addresses {
+		tx := cli.newTransaction(ctx, client, from, &cli.nonce, address, amountWei)
+		signTx, err := cli.signTx(from, tx)
+		if err == nil {
+			err = client.SendTransaction(ctx, signTx)
+		}
+		if err != nil {
+			statuses[i] = "failed: " + err.Error()
+			continue
+		}
+		cli.nonce++
+		txs[i], statuses[i] = signTx, "sent"
+	}
+
+	if wait {
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		fmt.Println("Waiting for the receipts...")
+		for i, tx := range txs {
+			if tx == nil {
+				continue
+			}
+			receipt, err := bind.WaitMined(waitCtx, client, tx)
+			if err != nil {
+				statuses[i] = "pending: " + err.Error()
+				continue
+			}
+			statuses[i] = receiptStatus(receipt)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tTX HASH\tSTATUS")
+	for i, address := range addresses {
+		hash := "-"
+		if txs[i] != nil {
+			hash = txs[i].Hash().Hex()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", address.Hex(), hash, statuses[i])
+	}
+	w.Flush()
+}
+
 func (cli *CLI) buildAccountListCmd() *cobra.Command {
 	accountListCmd := &cobra.Command{
 		Use:   "list",
@@ -74,9 +204,13 @@ func (cli *CLI) buildAccountListCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 		Run: func(cmd *cobra.Command, args []string) {
 			walletPath := cli.walletPath
-			wallet := keystore.NewKeyStore(walletPath,
-				keystore.LightScryptN, keystore.LightScryptP)
-			if len(wallet.Accounts()) == 0 {
+			wallet := newKeyStore(walletPath)
+			hdFile := viper.GetString("hd.file")
+			hd, err := loadHDWallet(hdFile)
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Println("Error:", err)
+			}
+			if len(wallet.Accounts()) == 0 && hd == nil {
 				fmt.Println("Empty wallet, create account first.")
 				return
 			}
@@ -84,8 +218,658 @@ func (cli *CLI) buildAccountListCmd() *cobra.Command {
 			for _, account := range wallet.Accounts() {
 				fmt.Println(account.Address.Hex())
 			}
+			if hd != nil {
+				for i, address := range hd.Addresses {
+					fmt.Printf("%s %s/%d\n", address.Hex(), hd.Path, i)
+				}
+			}
 		},
 	}
 
 	return accountListCmd
 }
+
+func (cli *CLI) buildAccountImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "import [keyfile | --keystore file | --mnemonic [--path m/44'/60'/0'/0/0]] [--setFrom]",
+		Short:                 "Import an account from a private key, a keystore file or a mnemonic",
+		Long:                  "Import an account to the wallet path from the hex private key in the keyfile, or stdin if not set, from a keystore file encrypted again with a new password, or from the BIP-39 mnemonic read from stdin.",
+		Args:                  cobra.MaximumNArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			keystoreFile, _ := cmd.Flags().GetString("keystore")
+			useMnemonic, _ := cmd.Flags().GetBool("mnemonic")
+			sources := len(args)
+			if keystoreFile != "" {
+				sources++
+			}
+			if useMnemonic {
+				sources++
+			}
+			if sources > 1 {
+				fmt.Println("Error: only one of keyfile, --keystore and --mnemonic allowed")
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+
+			wallet := newKeyStore(cli.walletPath)
+
+			var account accounts.Account
+			if keystoreFile != "" {
+				keyJSON, err := os.ReadFile(keystoreFile)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				password, err := prompt.Stdin.PromptPassword("Enter the passphrase of the keystore file: ")
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				key, err := keystore.DecryptKey(keyJSON, password)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				newPassword, err := getPassPhrase("Your imported account is locked with a new password. Please give a password. Do not forget this password.", true)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				account, err = wallet.ImportECDSA(key.PrivateKey, newPassword)
+				if err != nil {
+					fmt.Println("Import error:", err)
+					return
+				}
+			} else {
+				var privateKey *ecdsa.PrivateKey
+				var err error
+				if useMnemonic {
+					path, _ := cmd.Flags().GetString("path")
+					seedPassword, _ := cmd.Flags().GetBool("seedPassword")
+					privateKey, err = readMnemonicKey(path, seedPassword)
+				} else {
+					keyFile := ""
+					if len(args) > 0 {
+						keyFile = args[0]
+					}
+					privateKey, err = readPrivateKey(keyFile)
+				}
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+
+				password, err := getPassPhrase("Your imported account is locked with a password. Please give a password. Do not forget this password.", true)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				account, err = wallet.ImportECDSA(privateKey, password)
+				if err != nil {
+					fmt.Println("Import error:", err)
+					return
+				}
+			}
+			fmt.Println(account.Address.Hex())
+
+			if setFrom, _ := cmd.Flags().GetBool("setFrom"); setFrom {
+				if err := setConfigValue(cli.config, "faucet.from", account.Address.Hex()); err != nil {
+					fmt.Println("WriteConfig:", err)
+					return
+				}
+				showSuccess("faucet.from set to %s in %s", account.Address.Hex(), cli.config)
+			}
+		},
+	}
+
+	cmd.Flags().String("keystore", "", "Import the keystore `file`")
+	cmd.Flags().Bool("mnemonic", false, "Import the account derived from the BIP-39 mnemonic read from stdin")
+	cmd.Flags().String("path", defaultDerivationPath, "The BIP-44 derivation `path` of the mnemonic account")
+	cmd.Flags().Bool("seedPassword", false, "Ask for the BIP-39 passphrase of the mnemonic")
+	cmd.Flags().Bool("setFrom", false, "Set the imported account as faucet.from in the config file")
+
+	return cmd
+}
+
+// readPrivateKey reads the hex private key from the file, or stdin if the
+// file is empty or "-"
+func readPrivateKey(keyFile string) (*ecdsa.PrivateKey, error) {
+	var keyHex string
+	if keyFile == "" || keyFile == "-" {
+		var err error
+		keyHex, err = prompt.Stdin.PromptPassword("Enter the hex private key: ")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		keyHex = string(data)
+	}
+
+	keyHex = strings.TrimPrefix(strings.TrimSpace(keyHex), "0x")
+	privateKey, err := crypto.HexToECDSA(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+	return privateKey, nil
+}
+
+// readMnemonicKey reads the mnemonic from stdin and derives the key of the
+// path, with the BIP-39 passphrase if askPassphrase
+func readMnemonicKey(pathStr string, askPassphrase bool) (*ecdsa.PrivateKey, error) {
+	path, err := accounts.ParseDerivationPath(pathStr)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic, err := prompt.Stdin.PromptPassword("Enter the mnemonic: ")
+	if err != nil {
+		return nil, err
+	}
+	var passphrase string
+	if askPassphrase {
+		if passphrase, err = prompt.Stdin.PromptPassword("Enter the BIP-39 passphrase: "); err != nil {
+			return nil, err
+		}
+	}
+
+	seed, err := seedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return deriveKey(seed, path)
+}
+
+// findKeyFile returns the keystore file of the address in the wallet path,
+// or the argument itself if it is a file
+func (cli *CLI) findKeyFile(addressOrFile string) (string, error) {
+	if !common.IsHexAddress(addressOrFile) {
+		if _, err := os.Stat(addressOrFile); err != nil {
+			return "", err
+		}
+		return addressOrFile, nil
+	}
+
+	wallet := newKeyStore(cli.walletPath)
+	account, err := wallet.Find(accounts.Account{Address: common.HexToAddress(addressOrFile)})
+	if err != nil {
+		return "", fmt.Errorf("keystore find account(%s) error(%v)", addressOrFile, err)
+	}
+	return account.URL.Path, nil
+}
+
+// decryptKeyFile reads the keystore file and decrypts it with the password prompted
+func decryptKeyFile(file string) (*keystore.Key, error) {
+	keyJSON, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	password, err := prompt.Stdin.PromptPassword("Enter the passphrase of the account: ")
+	if err != nil {
+		return nil, err
+	}
+	return keystore.DecryptKey(keyJSON, password)
+}
+
+func (cli *CLI) buildAccountExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "export <address|keyfile> [-o file] [--privateKey] [--scryptN 262144] [--scryptP 1] [-y]",
+		Short:                 "Export the account as a keystore file encrypted with a new password, or as the private key",
+		Args:                  cobra.ExactArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			file, err := cli.findKeyFile(args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			key, err := decryptKeyFile(file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			out, _ := cmd.Flags().GetString("out")
+			if out != "" {
+				if _, err := os.Stat(out); err == nil {
+					fmt.Printf("Error: %s already exists\n", out)
+					return
+				}
+			}
+			privateKey, _ := cmd.Flags().GetBool("privateKey")
+			if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+				what, to := "keystore file", out
+				if privateKey {
+					what = "private key in plain text"
+				}
+				if to == "" {
+					to = "stdout"
+				}
+				confirmed, err := prompt.Stdin.PromptConfirm(fmt.Sprintf("Export the %s of %s to %s?", what, key.Address.Hex(), to))
+				if err != nil || !confirmed {
+					fmt.Println("Canceled")
+					return
+				}
+			}
+
+			var data []byte
+			if privateKey {
+				data = []byte(hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)) + "\n")
+			} else {
+				scryptN, scryptP := scryptParams()
+				if cmd.Flags().Changed("scryptN") {
+					scryptN, _ = cmd.Flags().GetInt("scryptN")
+				}
+				if cmd.Flags().Changed("scryptP") {
+					scryptP, _ = cmd.Flags().GetInt("scryptP")
+				}
+				password, err := getPassPhrase("The exported keystore file is locked with a new password. Please give a password. Do not forget this password.", true)
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				if data, err = keystore.EncryptKey(key, password, scryptN, scryptP); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				data = append(data, '\n')
+			}
+
+			if out == "" {
+				os.Stdout.Write(data)
+				return
+			}
+			f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if _, err := f.Write(data); err != nil {
+				f.Close()
+				fmt.Println("Error:", err)
+				return
+			}
+			if err := f.Close(); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			showSuccess("%s exported to %s", key.Address.Hex(), out)
+		},
+	}
+
+	cmd.Flags().StringP("out", "o", "", "The `file` to write, stdout if not set")
+	cmd.Flags().Bool("privateKey", false, "Export the hex private key in plain text instead of a keystore file")
+	cmd.Flags().Int("scryptN", keystore.StandardScryptN, "The scrypt `N` of the exported keystore file (default keystore.scryptN in the config file)")
+	cmd.Flags().Int("scryptP", keystore.StandardScryptP, "The scrypt `P` of the exported keystore file (default keystore.scryptP in the config file)")
+	cmd.Flags().BoolP("yes", "y", false, "Export without confirmation")
+	return cmd
+}
+
+func (cli *CLI) buildAccountUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "update <address>",
+		Short:                 "Change the password of the account",
+		Args:                  cobra.ExactArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !common.IsHexAddress(args[0]) {
+				fmt.Println("Error: invalid address", args[0])
+				return
+			}
+			wallet := newKeyStore(cli.walletPath)
+			account, err := wallet.Find(accounts.Account{Address: common.HexToAddress(args[0])})
+			if err != nil {
+				fmt.Printf("Error: keystore find account(%s) error(%v)\n", args[0], err)
+				return
+			}
+
+			password, err := prompt.Stdin.PromptPassword("Enter the current passphrase of the account: ")
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			newPassword, err := getPassPhrase("Please give a new password. Do not forget this password.", true)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if err := wallet.Update(account, password, newPassword); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			showSuccess("Password of %s updated", account.Address.Hex())
+		},
+	}
+
+	return cmd
+}
+
+func (cli *CLI) buildAccountInspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "inspect <address|keyfile>",
+		Short:                 "Show the keystore file of the account and check its password",
+		Args:                  cobra.ExactArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			file, err := cli.findKeyFile(args[0])
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			keyJSON, data, err := readKeyFile(file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "Address:\t%s\n", common.HexToAddress(keyJSON.Address).Hex())
+			fmt.Fprintf(w, "File:\t%s\n", file)
+			fmt.Fprintf(w, "Cipher:\t%s\n", keyJSON.Crypto.Cipher)
+			if scryptN, scryptP, ok := keyJSON.scrypt(); ok {
+				fmt.Fprintf(w, "KDF:\tscrypt (N=%d, P=%d)\n", scryptN, scryptP)
+			} else {
+				fmt.Fprintf(w, "KDF:\t%s\n", keyJSON.Crypto.KDF)
+			}
+			w.Flush()
+
+			password, err := prompt.Stdin.PromptPassword("Enter the passphrase to check: ")
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			key, err := keystore.DecryptKey(data, password)
+			if err != nil {
+				fmt.Println("Password: incorrect,", err)
+				return
+			}
+			if key.Address != common.HexToAddress(keyJSON.Address) {
+				fmt.Printf("Error: the key is of %s, not the address of the file\n", key.Address.Hex())
+				return
+			}
+			fmt.Println("Password: correct")
+		},
+	}
+
+	return cmd
+}
+
+// keyFile is the part of a keystore file to inspect
+type keyFile struct {
+	Address string              `json:"address"`
+	Crypto  keystore.CryptoJSON `json:"crypto"`
+}
+
+// readKeyFile reads the keystore file, and returns it with the raw data
+func readKeyFile(file string) (keyFile, []byte, error) {
+	var keyJSON keyFile
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return keyJSON, nil, err
+	}
+	if err := json.Unmarshal(data, &keyJSON); err != nil {
+		return keyJSON, nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return keyJSON, data, nil
+}
+
+// scrypt returns the scrypt N and P of the key, and false if it is not
+// encrypted with scrypt
+func (k keyFile) scrypt() (int, int, bool) {
+	if k.Crypto.KDF != "scrypt" {
+		return 0, 0, false
+	}
+	n, _ := k.Crypto.KDFParams["n"].(float64)
+	p, _ := k.Crypto.KDFParams["p"].(float64)
+	return int(n), int(p), true
+}
+
+func (cli *CLI) buildAccountUpgradeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "upgrade [address...] [--force]",
+		Short:                 "Encrypt the accounts again with the scrypt strength of the config",
+		Long:                  "Encrypt the keystore files of the accounts, or all accounts in the wallet path, again with keystore.scryptN and keystore.scryptP in the config file. The addresses and the passwords do not change.",
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			scryptN, scryptP := scryptParams()
+			wallet := newKeyStore(cli.walletPath)
+
+			var upgrading []accounts.Account
+			if len(args) == 0 {
+				upgrading = wallet.Accounts()
+			}
+			for _, arg := range args {
+				if !common.IsHexAddress(arg) {
+					fmt.Println("Error: invalid address", arg)
+					return
+				}
+				account, err := wallet.Find(accounts.Account{Address: common.HexToAddress(arg)})
+				if err != nil {
+					fmt.Printf("Error: keystore find account(%s) error(%v)\n", arg, err)
+					return
+				}
+				upgrading = append(upgrading, account)
+			}
+			if len(upgrading) == 0 {
+				fmt.Println("Empty wallet, create account first.")
+				return
+			}
+
+			force, _ := cmd.Flags().GetBool("force")
+			for _, account := range upgrading {
+				keyJSON, _, err := readKeyFile(account.URL.Path)
+				if err != nil {
+					fmt.Printf("%s: Error: %v\n", account.Address.Hex(), err)
+					continue
+				}
+				oldN, oldP, ok := keyJSON.scrypt()
+				if ok && oldN*oldP >= scryptN*scryptP && !force {
+					fmt.Printf("%s: scrypt N=%d P=%d not weaker, skipped\n", account.Address.Hex(), oldN, oldP)
+					continue
+				}
+
+				password, err := prompt.Stdin.PromptPassword(fmt.Sprintf("Enter the passphrase of %s: ", account.Address.Hex()))
+				if err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+				if err := wallet.Update(account, password, password); err != nil {
+					fmt.Printf("%s: Error: %v\n", account.Address.Hex(), err)
+					continue
+				}
+				fmt.Printf("%s: upgraded from scrypt N=%d P=%d to N=%d P=%d\n", account.Address.Hex(), oldN, oldP, scryptN, scryptP)
+			}
+		},
+	}
+
+	cmd.Flags().Bool("force", false, "Encrypt the accounts not weaker than the config too")
+	return cmd
+}
+
+func (cli *CLI) buildAccountHDCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hd [new|import|derive]",
+		Short: "Manage the HD wallet of the funding accounts",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			return
+		},
+	}
+
+	cmd.AddCommand(cli.buildAccountHDNewCmd())
+	cmd.AddCommand(cli.buildAccountHDImportCmd())
+	cmd.AddCommand(cli.buildAccountHDDeriveCmd())
+
+	return cmd
+}
+
+func (cli *CLI) buildAccountHDNewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "new [--words 24] [-n number]",
+		Short:                 "Create the HD wallet with a new mnemonic",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if hdWalletExists() {
+				return
+			}
+			words, _ := cmd.Flags().GetInt("words")
+			if words%3 != 0 || words < 12 || words > 24 {
+				fmt.Println("Error: words must be 12, 15, 18, 21 or 24")
+				return
+			}
+			entropy, err := bip39.NewEntropy(words / 3 * 32)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			mnemonic, err := bip39.NewMnemonic(entropy)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			fmt.Println("Write down the mnemonic and keep it safe, it is the only backup of the HD wallet:")
+			fmt.Println()
+			fmt.Println(mnemonic)
+			fmt.Println()
+
+			n, _ := cmd.Flags().GetInt("number")
+			cli.createHDWallet(bip39.NewSeed(mnemonic, ""), n)
+		},
+	}
+
+	cmd.Flags().Int("words", 24, "The number of the words of the mnemonic")
+	cmd.Flags().IntP("number", "n", 1, "The number of the accounts to derive, at least hd.accounts in the config file")
+	return cmd
+}
+
+func (cli *CLI) buildAccountHDImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "import [--seedPassword] [-n number]",
+		Short:                 "Create the HD wallet from the BIP-39 mnemonic read from stdin",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if hdWalletExists() {
+				return
+			}
+			mnemonic, err := prompt.Stdin.PromptPassword("Enter the mnemonic: ")
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			var passphrase string
+			if seedPassword, _ := cmd.Flags().GetBool("seedPassword"); seedPassword {
+				if passphrase, err = prompt.Stdin.PromptPassword("Enter the BIP-39 passphrase: "); err != nil {
+					fmt.Println("Error:", err)
+					return
+				}
+			}
+			seed, err := seedFromMnemonic(mnemonic, passphrase)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			n, _ := cmd.Flags().GetInt("number")
+			cli.createHDWallet(seed, n)
+		},
+	}
+
+	cmd.Flags().Bool("seedPassword", false, "Ask for the BIP-39 passphrase of the mnemonic")
+	cmd.Flags().IntP("number", "n", 1, "The number of the accounts to derive, at least hd.accounts in the config file")
+	return cmd
+}
+
+// hdWalletExists reports whether `hd.file` exists, so it is not overwritten
+func hdWalletExists() bool {
+	file := viper.GetString("hd.file")
+	if _, err := os.Stat(file); err == nil {
+		fmt.Printf("Error: %s already exists\n", file)
+		return true
+	}
+	return false
+}
+
+// createHDWallet saves the seed to `hd.file` encrypted with a new password,
+// with the addresses of the first n accounts, or `hd.accounts` if more
+func (cli *CLI) createHDWallet(seed []byte, n int) {
+	file := viper.GetString("hd.file")
+	if accounts := viper.GetInt("hd.accounts"); n < accounts {
+		n = accounts
+	}
+
+	password, err := getPassPhrase("Your HD wallet is locked with a password. Please give a password. Do not forget this password.", true)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		return
+	}
+	scryptN, scryptP := scryptParams()
+	hd, err := newHDWallet(seed, viper.GetString("hd.path"), password, n, scryptN, scryptP)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := hd.save(file); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	for i, address := range hd.Addresses {
+		fmt.Printf("%s %s/%d\n", address.Hex(), hd.Path, i)
+	}
+	showSuccess("HD wallet saved in %s, set faucet.signer to \"hd\" to use it", file)
+}
+
+func (cli *CLI) buildAccountHDDeriveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "derive -n number",
+		Short:                 "Derive the addresses of the first number of accounts of the HD wallet",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			n, _ := cmd.Flags().GetInt("number")
+			file := viper.GetString("hd.file")
+			hd, err := loadHDWallet(file)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if n <= len(hd.Addresses) {
+				fmt.Printf("%d accounts derived already\n", len(hd.Addresses))
+				return
+			}
+
+			password, err := getPassPhrase(fmt.Sprintf("Unlocking HD wallet %s", file), false)
+			if err != nil {
+				fmt.Println("Error: ", err)
+				return
+			}
+			seed, err := hd.seed(password)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			from := len(hd.Addresses)
+			if err := hd.deriveAddresses(seed, n); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			if err := hd.save(file); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			for i := from; i < n; i++ {
+				fmt.Printf("%s %s/%d\n", hd.Addresses[i].Hex(), hd.Path, i)
+			}
+		},
+	}
+
+	cmd.Flags().IntP("number", "n", 1, "The number of the accounts")
+	return cmd
+}
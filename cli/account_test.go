@@ -1,6 +1,14 @@
 package cli
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/viper"
+)
 
 func TestAccount(t *testing.T) {
 	cli := NewCLI()
@@ -9,8 +17,54 @@ func TestAccount(t *testing.T) {
 	cli.TestCommand("account new -w /tmp/walletPath")
 
 	cli.TestCommand("account new -n 10 -w /tmp/walletPath")
+	cli.TestCommand("account new --faucet -a 1.2.3 -w /tmp/walletPath")
 
 	cli.TestCommand("account list")
 	cli.TestCommand("account list -w /tmp/empty")
 
+	cli.TestCommand("account inspect /tmp/empty/none.json")
+	cli.TestCommand("account export 0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481 -w /tmp/empty")
+	cli.TestCommand("account update 0x1234")
+
+}
+
+func TestReadPrivateKey(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := readPrivateKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := crypto.PubkeyToAddress(key.PublicKey).Hex(); address != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("wrong address %s", address)
+	}
+
+	os.WriteFile(keyFile, []byte("0x1234"), 0600)
+	if _, err := readPrivateKey(keyFile); err == nil {
+		t.Error("invalid private key accepted")
+	}
+}
+
+func TestKeyStoreScrypt(t *testing.T) {
+	viper.Set("keystore.scryptN", 1<<13)
+	viper.Set("keystore.scryptP", 2)
+	defer viper.Set("keystore.scryptN", nil)
+	defer viper.Set("keystore.scryptP", nil)
+
+	account, err := newKeyStore(t.TempDir()).NewAccount("pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyJSON, _, err := readKeyFile(account.URL.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, p, ok := keyJSON.scrypt(); !ok || n != 1<<13 || p != 2 {
+		t.Errorf("wrong scrypt params N=%d P=%d", n, p)
+	}
+	if common.HexToAddress(keyJSON.Address) != account.Address {
+		t.Errorf("wrong address %s", keyJSON.Address)
+	}
 }
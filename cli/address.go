@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// NewAddressPrefix is the prefix of the NEW format address
+const NewAddressPrefix = "NEW"
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// toNewAddress converts the hex address to NEW format with the chain ID
+func toNewAddress(chainID *big.Int, address common.Address) string {
+	input := append(chainID.Bytes(), address.Bytes()...)
+	return NewAddressPrefix + base58CheckEncode(input, 0)
+}
+
+// parseNewAddress returns the chain ID and address of the NEW format address
+func parseNewAddress(newAddress string) (*big.Int, common.Address, error) {
+	if !strings.HasPrefix(newAddress, NewAddressPrefix) {
+		return nil, common.Address{}, fmt.Errorf("not NEW format address")
+	}
+	decoded, version, err := base58CheckDecode(newAddress[len(NewAddressPrefix):])
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	if version != 0 || len(decoded) <= common.AddressLength {
+		return nil, common.Address{}, fmt.Errorf("not valid NEW format address")
+	}
+	chainID := new(big.Int).SetBytes(decoded[:len(decoded)-common.AddressLength])
+	address := common.BytesToAddress(decoded[len(decoded)-common.AddressLength:])
+
+	return chainID, address, nil
+}
+
+// parseAddress accepts both hex and NEW format address. The chain ID of
+// the NEW format address must be equal to chainID if chainID is not nil.
+func parseAddress(addressStr string, chainID *big.Int) (common.Address, error) {
+	if common.IsHexAddress(addressStr) {
+		return common.HexToAddress(addressStr), nil
+	}
+	if strings.HasPrefix(addressStr, NewAddressPrefix) {
+		id, address, err := parseNewAddress(addressStr)
+		if err != nil {
+			return common.Address{}, newFaucetError("address.invalidNew")
+		}
+		if chainID != nil && id.Cmp(chainID) != 0 {
+			return common.Address{}, newFaucetError("address.chainMismatch", id, chainID)
+		}
+		return address, nil
+	}
+
+	return common.Address{}, newFaucetError("address.invalid")
+}
+
+func checksum(input []byte) []byte {
+	h := sha256.Sum256(input)
+	h2 := sha256.Sum256(h[:])
+	return h2[:4]
+}
+
+func base58CheckEncode(input []byte, version byte) string {
+	b := make([]byte, 0, 1+len(input)+4)
+	b = append(b, version)
+	b = append(b, input...)
+	b = append(b, checksum(b)...)
+	return base58Encode(b)
+}
+
+func base58CheckDecode(input string) ([]byte, byte, error) {
+	decoded, err := base58Decode(input)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(decoded) < 5 {
+		return nil, 0, fmt.Errorf("invalid format: version and/or checksum bytes missing")
+	}
+	payload := decoded[:len(decoded)-4]
+	if !bytes.Equal(checksum(payload), decoded[len(decoded)-4:]) {
+		return nil, 0, fmt.Errorf("checksum error")
+	}
+
+	return payload[1:], payload[0], nil
+}
+
+func base58Encode(input []byte) string {
+	x := new(big.Int).SetBytes(input)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for x.Sign() > 0 {
+		x.DivMod(x, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range input {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	return string(out)
+}
+
+func base58Decode(input string) ([]byte, error) {
+	x := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range input {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		x.Mul(x, radix)
+		x.Add(x, big.NewInt(int64(i)))
+	}
+
+	var zeros int
+	for zeros < len(input) && input[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+
+	return append(make([]byte, zeros), x.Bytes()...), nil
+}
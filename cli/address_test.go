@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNewAddress(t *testing.T) {
+	address := common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481")
+	chainID := big.NewInt(1007)
+
+	newAddress := toNewAddress(chainID, address)
+	id, got, err := parseNewAddress(newAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Cmp(chainID) != 0 || got != address {
+		t.Errorf("wrong parse of %s: want %v %v, got %v %v", newAddress, chainID, address.Hex(), id, got.Hex())
+	}
+
+	if _, err := parseAddress(newAddress, big.NewInt(1012)); err == nil {
+		t.Errorf("chain ID mismatch of %s not detected", newAddress)
+	}
+	if _, err := parseAddress(newAddress[:len(newAddress)-1]+"1", chainID); err == nil {
+		t.Errorf("checksum error of %s not detected", newAddress)
+	}
+}
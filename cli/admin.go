@@ -0,0 +1,542 @@
+package cli
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/viper"
+)
+
+const defaultAuditLog = "./audit.log"
+
+// maxLastErrors is the number of the recent payout errors kept for the status
+const maxLastErrors = 10
+
+// adminStatus is the body of /admin/v1/status
+type adminStatus struct {
+	Version       string         `json:"version"`
+	Uptime        string         `json:"uptime"`
+	UptimeSeconds int64          `json:"uptimeSeconds"`
+	Paused        bool           `json:"paused"`
+	Address       string         `json:"address"`
+	Balance       string         `json:"balance"` // wei of all accounts, empty if unknown
+	BalanceText   string         `json:"balanceText"`
+	Amount        string         `json:"amount"`
+	Unit          string         `json:"unit"`
+	AmountWei     string         `json:"amountWei"`
+	Cooldown      string         `json:"cooldown"`
+	QueueDepth    int            `json:"queueDepth"`
+	PendingTxs    int            `json:"pendingTxs"`
+	Nonce         uint64         `json:"nonce"` // next nonce to use of the address
+	Accounts      []adminAccount `json:"accounts"`
+	LastErrors    []adminError   `json:"lastErrors"`
+}
+
+// adminAccount is the state of a faucet account paying out, all HD accounts
+// with the HD wallet
+type adminAccount struct {
+	Address     string `json:"address"`
+	Nonce       uint64 `json:"nonce"`
+	Balance     string `json:"balance"` // wei, empty if unknown
+	BalanceText string `json:"balanceText"`
+}
+
+// adminError is a recent payout error
+type adminError struct {
+	Time  time.Time `json:"time"`
+	Error string    `json:"error"`
+}
+
+// adminSettings is the body of POST /admin/v1/settings, nil fields are unchanged
+type adminSettings struct {
+	Amount   *string `json:"amount,omitempty"`
+	Unit     *string `json:"unit,omitempty"`
+	Cooldown *string `json:"cooldown,omitempty"`
+}
+
+// adminQueue is the body of /admin/v1/queue
+type adminQueue struct {
+	Queued  []ticket `json:"queued"`
+	Sending []ticket `json:"sending"` // signed, maybe not broadcast
+	Sent    []ticket `json:"sent"`    // broadcast but not mined
+}
+
+// adminNonce is the body of /admin/v1/nonce, the nonces of the address and
+// of each faucet account
+type adminNonce struct {
+	Address  string       `json:"address,omitempty"`
+	Local    uint64       `json:"local"` // next nonce to use
+	Latest   uint64       `json:"latest"`
+	Pending  uint64       `json:"pending"`
+	Accounts []adminNonce `json:"accounts,omitempty"`
+}
+
+// adminBan is the body of POST and DELETE /admin/v1/bans
+type adminBan struct {
+	Address string `json:"address,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// unixSocketKey marks the context of the connections from the unix socket
+type unixSocketKey struct{}
+
+// auditEntry is a line of the audit log
+type auditEntry struct {
+	Time   time.Time   `json:"time"`
+	Action string      `json:"action"`
+	Remote string      `json:"remote"`
+	Params interface{} `json:"params,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// auditLog appends the admin actions, the read-only ones included, to a file
+// as JSON lines
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file}, nil
+}
+
+func (a *auditLog) record(r *http.Request, action string, params interface{}, err error) {
+	entry := auditEntry{
+		Time:   time.Now(),
+		Action: action,
+		Remote: r.RemoteAddr,
+		Params: params,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	log.Printf("admin %s from %s: %v", action, r.RemoteAddr, err)
+
+	data, _ := json.Marshal(entry)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		log.Printf("write audit log error: %v", err)
+	}
+}
+
+// startAdmin serves the admin API on `admin.listen` and `admin.socket`
+// if either is set.
+func (cli *CLI) startAdmin() error {
+	listen := viper.GetString("admin.listen")
+	socket := viper.GetString("admin.socket")
+	if listen == "" && socket == "" {
+		return nil
+	}
+	if listen != "" && viper.GetString("admin.token") == "" {
+		return fmt.Errorf("admin.token required to listen on %s", listen)
+	}
+
+	audit, err := newAuditLog(viper.GetString("admin.auditLog"))
+	if err != nil {
+		return err
+	}
+	cli.audit = audit
+
+	var listeners []net.Listener
+	if listen != "" {
+		l, err := net.Listen("tcp", listen)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+	if socket != "" {
+		l, err := listenPrivateUnix(socket)
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+	}
+
+	server := &http.Server{
+		Handler:           cli.adminHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			if _, ok := c.(*net.UnixConn); ok {
+				return context.WithValue(ctx, unixSocketKey{}, true)
+			}
+			return ctx
+		},
+	}
+	cli.admin = server
+	for _, l := range listeners {
+		fmt.Printf("Admin serve started(%v)\n", l.Addr())
+		go func(l net.Listener) {
+			if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+				log.Printf("admin serve error: %v", err)
+			}
+		}(l)
+	}
+
+	return nil
+}
+
+func (cli *CLI) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/v1/status", cli.adminStatusHandler)
+	mux.HandleFunc("POST /admin/v1/pause", cli.adminPauseHandler)
+	mux.HandleFunc("POST /admin/v1/resume", cli.adminResumeHandler)
+	mux.HandleFunc("POST /admin/v1/settings", cli.adminSettingsHandler)
+	mux.HandleFunc("GET /admin/v1/queue", cli.adminQueueHandler)
+	mux.HandleFunc("GET /admin/v1/nonce", cli.adminNonceHandler)
+	mux.HandleFunc("POST /admin/v1/nonce/resync", cli.adminResyncHandler)
+	mux.HandleFunc("GET /admin/v1/bans", cli.adminBansHandler)
+	mux.HandleFunc("POST /admin/v1/bans", cli.adminBanHandler)
+	mux.HandleFunc("DELETE /admin/v1/bans", cli.adminUnbanHandler)
+
+	return adminAuth(viper.GetString("admin.token"), mux)
+}
+
+// adminAuth requires the bearer token if the request is not from the unix
+// socket, which is protected by the file permission.
+func adminAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromSocket, _ := r.Context().Value(unixSocketKey{}).(bool)
+		if !fromSocket {
+			got, ok := bearerToken(r)
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, apiError{Error: "unauthorized"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of the header `Authorization: Bearer <token>`,
+// the scheme is case insensitive
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	return auth[len(prefix):], true
+}
+
+func writeAdminError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
+
+func (cli *CLI) adminStatusHandler(w http.ResponseWriter, r *http.Request) {
+	uptime := time.Since(cli.startTime).Round(time.Second)
+
+	cli.mu.Lock()
+	status := adminStatus{
+		Version:       cli.version,
+		Uptime:        uptime.String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+		Paused:        cli.paused,
+		Address:       common.HexToAddress(cli.coinbase).Hex(),
+		Amount:        cli.amount,
+		Unit:          cli.unit,
+		AmountWei:     cli.amountWei.String(),
+		Cooldown:      cli.cooldown.String(),
+		LastErrors:    append([]adminError{}, cli.lastErrors...),
+	}
+	cli.mu.Unlock()
+
+	status.QueueDepth = len(cli.tickets.list(ticketQueued))
+	status.PendingTxs = len(cli.tickets.list(ticketSending)) + len(cli.tickets.list(ticketSent))
+
+	cli.sendMu.Lock()
+	status.Nonce = cli.nonce
+	for _, address := range cli.senderAddresses() {
+		status.Accounts = append(status.Accounts, adminAccount{Address: address.Hex(), Nonce: *cli.senderNonce(address)})
+	}
+	cli.sendMu.Unlock()
+
+	client, err := ethclient.Dial(cli.rpcURL)
+	if err == nil {
+		defer client.Close()
+		if balances, total, err := cli.senderBalances(r.Context(), client); err == nil {
+			status.Balance = total.String()
+			status.BalanceText = formatAmount(total, "")
+			for i, balance := range balances {
+				status.Accounts[i].Balance = balance.String()
+				status.Accounts[i].BalanceText = formatAmount(balance, "")
+			}
+		}
+	}
+
+	cli.audit.record(r, "status", nil, nil)
+	writeJSON(w, http.StatusOK, status)
+}
+
+// recordError keeps the payout error for the status, newest first
+func (cli *CLI) recordError(err error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	cli.lastErrors = append([]adminError{{Time: time.Now(), Error: err.Error()}}, cli.lastErrors...)
+	if len(cli.lastErrors) > maxLastErrors {
+		cli.lastErrors = cli.lastErrors[:maxLastErrors]
+	}
+}
+
+func (cli *CLI) setPaused(paused bool) {
+	cli.mu.Lock()
+	cli.paused = paused
+	cli.mu.Unlock()
+	if !paused {
+		cli.pauseCond.Broadcast()
+	}
+}
+
+func (cli *CLI) adminPauseHandler(w http.ResponseWriter, r *http.Request) {
+	cli.setPaused(true)
+	cli.audit.record(r, "pause", nil, nil)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+}
+
+func (cli *CLI) adminResumeHandler(w http.ResponseWriter, r *http.Request) {
+	cli.setPaused(false)
+	cli.audit.record(r, "resume", nil, nil)
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+}
+
+func (cli *CLI) adminSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var settings adminSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err := cli.updateSettings(settings)
+	cli.audit.record(r, "settings", settings, err)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	cli.adminStatusHandler(w, r)
+}
+
+// updateSettings changes the payout settings after all of them are valid
+func (cli *CLI) updateSettings(settings adminSettings) error {
+	// wait for the payout in progress
+	cli.sendMu.Lock()
+	defer cli.sendMu.Unlock()
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	amount, unit, cooldown := cli.amount, cli.unit, cli.cooldown
+	if settings.Amount != nil {
+		amount = *settings.Amount
+	}
+	if settings.Unit != nil {
+		unit = *settings.Unit
+	}
+	unit, ok := normalizeUnit(unit)
+	if !ok {
+		return fmt.Errorf("Unit(%s) for amount error. %s", unit, DenominationString)
+	}
+	amountWei, err := getAmountWei(amount, unit)
+	if err != nil {
+		return err
+	}
+	if amountWei.Sign() <= 0 {
+		return fmt.Errorf("Get amount error: %s", amount)
+	}
+	if settings.Cooldown != nil {
+		d, err := time.ParseDuration(*settings.Cooldown)
+		if err != nil {
+			return err
+		}
+		if d < 0 {
+			return fmt.Errorf("cooldown less than 0: %v", d)
+		}
+		cooldown = d
+	}
+
+	cli.amount, cli.unit, cli.amountWei, cli.cooldown = amount, unit, amountWei, cooldown
+	return nil
+}
+
+func (cli *CLI) adminQueueHandler(w http.ResponseWriter, r *http.Request) {
+	queue := adminQueue{
+		Queued:  cli.tickets.list(ticketQueued),
+		Sending: cli.tickets.list(ticketSending),
+		Sent:    cli.tickets.list(ticketSent),
+	}
+	if queue.Queued == nil {
+		queue.Queued = []ticket{}
+	}
+	if queue.Sending == nil {
+		queue.Sending = []ticket{}
+	}
+	if queue.Sent == nil {
+		queue.Sent = []ticket{}
+	}
+	cli.audit.record(r, "queue", nil, nil)
+	writeJSON(w, http.StatusOK, queue)
+}
+
+// chainNonce returns the latest and pending nonce of the account
+func chainNonce(ctx context.Context, client *ethclient.Client, address common.Address) (uint64, uint64, error) {
+	latest, err := client.NonceAt(ctx, address, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	pending, err := client.PendingNonceAt(ctx, address)
+	if err != nil {
+		return 0, 0, err
+	}
+	return latest, pending, nil
+}
+
+// senderNonces returns the nonces of the faucet account and of all faucet
+// accounts, with the local nonces reset to the pending nonces if resync. The
+// caller must hold cli.sendMu.
+func (cli *CLI) senderNonces(ctx context.Context, resync bool) (adminNonce, error) {
+	client, err := ethclient.Dial(cli.rpcURL)
+	if err != nil {
+		return adminNonce{}, err
+	}
+	defer client.Close()
+
+	var nonces adminNonce
+	for _, address := range cli.senderAddresses() {
+		latest, pending, err := chainNonce(ctx, client, address)
+		if err != nil {
+			return adminNonce{}, err
+		}
+		nonce := cli.senderNonce(address)
+		if resync {
+			*nonce = pending
+		}
+		nonces.Accounts = append(nonces.Accounts, adminNonce{Address: address.Hex(), Local: *nonce, Latest: latest, Pending: pending})
+	}
+	account := nonces.Accounts[0]
+	nonces.Address, nonces.Local, nonces.Latest, nonces.Pending = account.Address, account.Local, account.Latest, account.Pending
+
+	return nonces, nil
+}
+
+func (cli *CLI) adminNonceHandler(w http.ResponseWriter, r *http.Request) {
+	cli.sendMu.Lock()
+	nonces, err := cli.senderNonces(r.Context(), false)
+	cli.sendMu.Unlock()
+	cli.audit.record(r, "nonce", nil, err)
+	if err != nil {
+		writeAdminError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nonces)
+}
+
+// adminResyncHandler resets the local nonces of all faucet accounts to the
+// pending nonces of the chain
+func (cli *CLI) adminResyncHandler(w http.ResponseWriter, r *http.Request) {
+	cli.sendMu.Lock()
+	defer cli.sendMu.Unlock()
+
+	old := make(map[string]uint64)
+	for _, address := range cli.senderAddresses() {
+		old[address.Hex()] = *cli.senderNonce(address)
+	}
+	nonces, err := cli.senderNonces(r.Context(), true)
+	params := map[string]interface{}{"old": old}
+	if err == nil {
+		updated := make(map[string]uint64)
+		for _, account := range nonces.Accounts {
+			updated[account.Address] = account.Local
+		}
+		params["new"] = updated
+	}
+	cli.audit.record(r, "nonce.resync", params, err)
+	if err != nil {
+		writeAdminError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, nonces)
+}
+
+func (cli *CLI) adminBansHandler(w http.ResponseWriter, r *http.Request) {
+	cli.audit.record(r, "bans", nil, nil)
+	writeJSON(w, http.StatusOK, cli.bans.list())
+}
+
+// parseAdminBan returns the address of req if set
+func parseAdminBan(req adminBan) (*common.Address, error) {
+	if (req.Address == "") == (req.IP == "") {
+		return nil, fmt.Errorf("one of address and ip required")
+	}
+	if req.Address == "" {
+		return nil, nil
+	}
+	address, err := parseAddress(req.Address, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (cli *CLI) adminBanHandler(w http.ResponseWriter, r *http.Request) {
+	var req adminBan
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	address, err := parseAdminBan(req)
+	if err == nil {
+		err = cli.bans.add(ban{Address: address, IP: req.IP, Reason: req.Reason})
+	}
+	cli.audit.record(r, "ban", req, err)
+	if err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cli.bans.list())
+}
+
+func (cli *CLI) adminUnbanHandler(w http.ResponseWriter, r *http.Request) {
+	var req adminBan
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := http.StatusBadRequest
+	address, err := parseAdminBan(req)
+	if err == nil {
+		var ok bool
+		ok, err = cli.bans.remove(address, req.IP)
+		if err == nil && !ok {
+			status, err = http.StatusNotFound, fmt.Errorf("not banned")
+		}
+	}
+	cli.audit.record(r, "unban", req, err)
+	if err != nil {
+		writeAdminError(w, status, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cli.bans.list())
+}
@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestAdminAuth(t *testing.T) {
+	handler := adminAuth("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusNoContent,
+		"bearer secret": http.StatusNoContent,
+		"BEARER secret": http.StatusNoContent,
+		"secret":        http.StatusUnauthorized,
+		"Basic secret":  http.StatusUnauthorized,
+		"Bearersecret":  http.StatusUnauthorized,
+	} {
+		r := httptest.NewRequest("GET", "/admin/v1/status", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("(%s) wrong status: want %v, got %v", auth, want, w.Code)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/admin/v1/status", nil)
+	r = r.WithContext(context.WithValue(r.Context(), unixSocketKey{}, true))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("(socket) wrong status: want %v, got %v", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestUpdateSettings(t *testing.T) {
+	cli := newTestCLI()
+	cli.amount, cli.unit = "1", "NEW"
+	cli.amountWei, _ = getAmountWei(cli.amount, cli.unit)
+
+	amount, unit, cooldown := "100", "WEI", "1h"
+	if err := cli.updateSettings(adminSettings{Amount: &amount, Unit: &unit, Cooldown: &cooldown}); err != nil {
+		t.Fatal(err)
+	}
+	if cli.amountWei.Int64() != 100 || cli.cooldown != time.Hour {
+		t.Errorf("wrong settings: %v %v", cli.amountWei, cli.cooldown)
+	}
+
+	bad := "BTC"
+	if err := cli.updateSettings(adminSettings{Unit: &bad}); err == nil {
+		t.Errorf("unit %s accepted", bad)
+	}
+	if cli.unit != "WEI" {
+		t.Errorf("settings changed by invalid update: %v", cli.unit)
+	}
+}
+
+func TestAdminResyncHDAccounts(t *testing.T) {
+	eth := &stubEth{known: make(map[common.Hash]*types.Transaction), nonce: 9}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cli := newTestCLI()
+	cli.rpcURL = ts.URL
+	var err error
+	cli.audit, err = newAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := uint64(2)
+	cli.nonce = 1
+	cli.hdAccounts = []*hdAccount{
+		{address: common.HexToAddress("0x8709Fe1cB55C6aB630456C887af578e7bE9F7490"), nonce: &cli.nonce},
+		{address: common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"), nonce: &other},
+	}
+
+	w := httptest.NewRecorder()
+	cli.adminResyncHandler(w, httptest.NewRequest("POST", "/admin/v1/nonce/resync", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong status: %v %s", w.Code, w.Body)
+	}
+	var nonces adminNonce
+	if err := json.Unmarshal(w.Body.Bytes(), &nonces); err != nil {
+		t.Fatal(err)
+	}
+	if len(nonces.Accounts) != 2 || nonces.Accounts[1].Address != cli.hdAccounts[1].address.Hex() {
+		t.Fatalf("wrong accounts: %+v", nonces.Accounts)
+	}
+	if cli.nonce != 9 || other != 9 || nonces.Local != 9 || nonces.Accounts[1].Local != 9 {
+		t.Errorf("nonces not resynced: %d %d %+v", cli.nonce, other, nonces)
+	}
+}
+
+func TestAdminUnbanHandler(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	cli.audit, err = newAuditLog(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.bans, err = newBanList(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.bans.add(ban{IP: "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{}`, http.StatusBadRequest},
+		{`{"address":"0x8709Fe1cB55C6aB630456C887af578e7bE9F7490","ip":"192.168.1.1"}`, http.StatusBadRequest},
+		{`{"address":"not an address"}`, http.StatusBadRequest},
+		{`{"ip":"not an ip"}`, http.StatusBadRequest},
+		{`{"ip":"10.0.0.1"}`, http.StatusNotFound},
+		{`{"ip":"192.168.1.1"}`, http.StatusOK},
+		{`{"ip":"192.168.1.1"}`, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		cli.adminUnbanHandler(w, httptest.NewRequest("POST", "/admin/v1/unban", strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("(%s) wrong status: want %v, got %v", tt.body, tt.want, w.Code)
+		}
+	}
+}
+
+func TestAdminAuditReadOnly(t *testing.T) {
+	cli := newTestCLI()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	var err error
+	cli.audit, err = newAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.bans, err = newBanList(filepath.Join(t.TempDir(), "bans.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.tickets, err = newTicketStore(filepath.Join(t.TempDir(), "tickets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cli.adminBansHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/v1/bans", nil))
+	cli.adminQueueHandler(httptest.NewRecorder(), httptest.NewRequest("GET", "/admin/v1/queue", nil))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, entry.Action)
+	}
+	if strings.Join(actions, ",") != "bans,queue" {
+		t.Errorf("wrong audited actions: %v", actions)
+	}
+}
@@ -0,0 +1,288 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// apiError is the body of the API error response
+type apiError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // message ID of the error
+}
+
+// blockInfo is the brief of the block
+type blockInfo struct {
+	Number    uint64      `json:"number"`
+	Hash      common.Hash `json:"hash"`
+	Timestamp uint64      `json:"timestamp"`
+}
+
+// faucetInfo is the body of /api/v1/info
+type faucetInfo struct {
+	Version         string           `json:"version"`
+	Flavor          string           `json:"flavor"`
+	Address         string           `json:"address"`
+	NewAddress      string           `json:"newAddress"`
+	ChainID         string           `json:"chainId"`
+	Amount          string           `json:"amount"`
+	Unit            string           `json:"unit"`
+	AmountWei       string           `json:"amountWei"`
+	Cooldown        string           `json:"cooldown"`
+	CooldownSeconds int64            `json:"cooldownSeconds"`
+	Budget          *string          `json:"budget"`          // wei, null for unlimited
+	RemainingBudget *string          `json:"remainingBudget"` // wei, null for unlimited
+	Balance         string           `json:"balance"`         // wei of all accounts, empty if unknown
+	BalanceText     string           `json:"balanceText"`
+	Accounts        []accountBalance `json:"accounts"` // the faucet accounts paying out
+	LatestBlock     *blockInfo       `json:"latestBlock"`
+	PoWDifficulty   int              `json:"powDifficulty"`
+	PoWSalt         string           `json:"powSalt,omitempty"`
+}
+
+// accountBalance is the balance of a faucet account
+type accountBalance struct {
+	Address     string `json:"address"`
+	Balance     string `json:"balance"` // wei, empty if unknown
+	BalanceText string `json:"balanceText"`
+}
+
+// faucetRequest is the body of POST /api/v1/faucet
+type faucetRequest struct {
+	Address string `json:"address"`
+	PoW     string `json:"pow"`
+}
+
+// faucetResponse is the response of POST /api/v1/faucet
+type faucetResponse struct {
+	TxHash   common.Hash `json:"txHash"`
+	TicketID string      `json:"ticketId"`
+}
+
+// txStatus is the body of /api/v1/tx/{hash}
+type txStatus struct {
+	TxHash      common.Hash `json:"txHash"`
+	Status      string      `json:"status"` // pending, mined or failed
+	BlockNumber *uint64     `json:"blockNumber,omitempty"`
+	GasUsed     *uint64     `json:"gasUsed,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("write json error: %v", err)
+	}
+}
+
+// writeJSONError writes the error translated to the language of the request
+func (cli *CLI) writeJSONError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	resp := apiError{Error: cli.printer(r).Error(err)}
+	var fe *faucetError
+	if errors.As(err, &fe) {
+		resp.Code = fe.id
+	}
+	writeJSON(w, status, resp)
+}
+
+func flavor() string {
+	if isNewton() {
+		return "newton"
+	}
+	return "ethereum"
+}
+
+func (cli *CLI) infoHandler(w http.ResponseWriter, r *http.Request) {
+	coinbase := common.HexToAddress(cli.coinbase)
+
+	cli.mu.Lock()
+	info := faucetInfo{
+		Version:         cli.version,
+		Flavor:          flavor(),
+		Address:         coinbase.Hex(),
+		NewAddress:      toNewAddress(cli.networkID, coinbase),
+		ChainID:         cli.networkID.String(),
+		Amount:          cli.amount,
+		Unit:            cli.unit,
+		AmountWei:       cli.amountWei.String(),
+		Cooldown:        cli.cooldown.String(),
+		CooldownSeconds: int64(cli.cooldown.Seconds()),
+		PoWDifficulty:   cli.powDifficulty,
+	}
+	if cli.powDifficulty > 0 {
+		info.PoWSalt = cli.powSalt
+	}
+	for _, address := range cli.senderAddresses() {
+		info.Accounts = append(info.Accounts, accountBalance{Address: address.Hex()})
+	}
+	if cli.budgetWei != nil {
+		budget := cli.budgetWei.String()
+		remaining := cli.remainingBudget().String()
+		info.Budget = &budget
+		info.RemainingBudget = &remaining
+	}
+	cli.mu.Unlock()
+
+	client, err := ethclient.Dial(cli.rpcURL)
+	if err != nil {
+		log.Printf("client dial error: %v", err)
+		writeJSON(w, http.StatusOK, info)
+		return
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	if balances, total, err := cli.senderBalances(ctx, client); err != nil {
+		log.Printf("Balance error: %v", err)
+	} else {
+		info.Balance = total.String()
+		info.BalanceText = formatAmount(total, "")
+		for i, balance := range balances {
+			info.Accounts[i].Balance = balance.String()
+			info.Accounts[i].BalanceText = formatAmount(balance, "")
+		}
+	}
+
+	if header, err := client.HeaderByNumber(ctx, nil); err != nil {
+		log.Printf("HeaderByNumber error: %v", err)
+	} else {
+		info.LatestBlock = &blockInfo{
+			Number:    header.Number.Uint64(),
+			Hash:      header.Hash(),
+			Timestamp: header.Time,
+		}
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (cli *CLI) faucetAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var req faucetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("request.invalidBody", err))
+		return
+	}
+	if req.Address == "" {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("address.missing"))
+		return
+	}
+	log.Printf("faucet got address: %v", req.Address)
+
+	t, err := cli.payout(req.Address, req.PoW)
+	if err != nil {
+		cli.writeJSONError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, faucetResponse{TxHash: *t.TxHash, TicketID: t.ID})
+}
+
+func (cli *CLI) txStatusHandler(w http.ResponseWriter, r *http.Request) {
+	hashStr := r.PathValue("hash")
+	hashBytes, err := hexutil.Decode(hashStr)
+	if err != nil || len(hashBytes) != common.HashLength {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("tx.invalidHash"))
+		return
+	}
+	hash := common.BytesToHash(hashBytes)
+
+	client, err := ethclient.Dial(cli.rpcURL)
+	if err != nil {
+		log.Printf("client dial error: %v", err)
+		cli.writeJSONError(w, r, http.StatusBadGateway, err)
+		return
+	}
+	defer client.Close()
+	ctx := r.Context()
+
+	receipt, err := client.TransactionReceipt(ctx, hash)
+	if err == nil {
+		status := txStatus{
+			TxHash:      hash,
+			Status:      "mined",
+			BlockNumber: new(uint64),
+			GasUsed:     &receipt.GasUsed,
+		}
+		*status.BlockNumber = receipt.BlockNumber.Uint64()
+		if receipt.Status == types.ReceiptStatusFailed {
+			status.Status = "failed"
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+	if err != ethereum.NotFound {
+		cli.writeJSONError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	if _, _, err := client.TransactionByHash(ctx, hash); err != nil {
+		if err == ethereum.NotFound {
+			cli.writeJSONError(w, r, http.StatusNotFound, newFaucetError("tx.notFound"))
+		} else {
+			cli.writeJSONError(w, r, http.StatusBadGateway, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, txStatus{TxHash: hash, Status: "pending"})
+}
+
+// localizeTicket translates the error of the ticket to the language of the request
+func (cli *CLI) localizeTicket(r *http.Request, t ticket) ticket {
+	if t.ErrorCode != "" {
+		args := make([]interface{}, len(t.ErrorArgs))
+		for i, arg := range t.ErrorArgs {
+			args[i] = arg
+		}
+		t.Error = cli.printer(r).Sprintf(t.ErrorCode, args...)
+	}
+	return t
+}
+
+func (cli *CLI) createRequestHandler(w http.ResponseWriter, r *http.Request) {
+	var req faucetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("request.invalidBody", err))
+		return
+	}
+	if req.Address == "" {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("address.missing"))
+		return
+	}
+	log.Printf("faucet got address: %v", req.Address)
+
+	t, err := cli.enqueue(req.Address, req.PoW)
+	if err != nil {
+		status := http.StatusBadRequest
+		var fe *faucetError
+		if errors.As(err, &fe) && fe.id == "queue.full" {
+			status = http.StatusServiceUnavailable
+		}
+		cli.writeJSONError(w, r, status, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/requests/"+t.ID)
+	writeJSON(w, http.StatusAccepted, t)
+}
+
+func (cli *CLI) getRequestHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := cli.tickets.get(r.PathValue("id"))
+	if !ok {
+		cli.writeJSONError(w, r, http.StatusNotFound, newFaucetError("ticket.notFound"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cli.localizeTicket(r, t))
+}
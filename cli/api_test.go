@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// stubBalance is the eth API of a node with 1.5 NEW in every account
+type stubBalance struct{}
+
+func (stubBalance) GetBalance(address common.Address, block string) *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1500000000000000000))
+}
+
+func TestInfoHandler(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", stubBalance{}); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(server)
+	defer node.Close()
+
+	cli := newTestCLI()
+	cli.rpcURL = node.URL
+	cli.networkID = big.NewInt(1007)
+	cli.coinbase = "0x8709Fe1cB55C6aB630456C887af578e7bE9F7490"
+	cli.amount, cli.unit = "1", "NEW"
+	cli.amountWei, _ = getAmountWei(cli.amount, cli.unit)
+	cli.cooldown = time.Hour
+	cli.budgetWei, _ = getAmountWei("10", cli.unit)
+	var err error
+	cli.payouts, err = newPayoutLog(filepath.Join(t.TempDir(), "payouts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.recordPayout(common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"))
+
+	ts := httptest.NewServer(http.HandlerFunc(cli.infoHandler))
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/api/v1/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status: %v", resp.StatusCode)
+	}
+	var info faucetInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Address != cli.coinbase || info.ChainID != "1007" || info.AmountWei != "1000000000000000000" || info.CooldownSeconds != 3600 {
+		t.Errorf("wrong info: %+v", info)
+	}
+	if info.Budget == nil || *info.Budget != "10000000000000000000" || info.RemainingBudget == nil || *info.RemainingBudget != "9000000000000000000" {
+		t.Errorf("wrong budget: %v %v", info.Budget, info.RemainingBudget)
+	}
+	if info.Balance != "1500000000000000000" || len(info.Accounts) != 1 || info.Accounts[0].Balance != info.Balance {
+		t.Errorf("wrong balance: %s %+v", info.Balance, info.Accounts)
+	}
+	if info.LatestBlock != nil {
+		t.Errorf("latest block of a node without blocks: %+v", info.LatestBlock)
+	}
+}
@@ -0,0 +1,237 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// addressBalance is the balance of an address in /api/v1/balance
+type addressBalance struct {
+	Address string `json:"address"`
+	Wei     string `json:"wei,omitempty"`
+	Balance string `json:"balance,omitempty"` // in the unit
+	Text    string `json:"text,omitempty"`    // with the unit
+	Error   string `json:"error,omitempty"`
+}
+
+// balanceResponse is the body of /api/v1/balance
+type balanceResponse struct {
+	Block    string           `json:"block"`
+	Unit     string           `json:"unit,omitempty"` // empty for WEI or NEW by the amount
+	Balances []addressBalance `json:"balances"`
+}
+
+// parseBlockParam returns the block argument of the JSON-RPC for latest,
+// pending, earliest, a number in decimal or hex, or a block hash
+func parseBlockParam(block string) (interface{}, bool) {
+	switch block {
+	case "", "latest":
+		return "latest", true
+	case "pending", "earliest":
+		return block, true
+	}
+	if strings.HasPrefix(block, "0x") && len(block) == 2+2*common.HashLength {
+		hash, err := hexutil.Decode(block)
+		if err != nil {
+			return nil, false
+		}
+		// EIP-1898
+		return map[string]interface{}{"blockHash": common.BytesToHash(hash)}, true
+	}
+	var number uint64
+	var err error
+	if hex := strings.TrimPrefix(block, "0x"); hex != block {
+		number, err = strconv.ParseUint(hex, 16, 64)
+	} else {
+		number, err = strconv.ParseUint(block, 10, 64)
+	}
+	if err != nil {
+		return nil, false
+	}
+	return hexutil.EncodeUint64(number), true
+}
+
+// balanceAPIHandler returns the balances of up to `faucet.maxBalanceAddresses`
+// addresses with one batch of JSON-RPC calls
+func (cli *CLI) balanceAPIHandler(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var addressStrs []string
+	for _, value := range r.Form["address"] {
+		for _, address := range strings.Split(value, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				addressStrs = append(addressStrs, address)
+			}
+		}
+	}
+	if len(addressStrs) == 0 {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("address.missing"))
+		return
+	}
+	if max := viper.GetInt("faucet.maxBalanceAddresses"); len(addressStrs) > max {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("balance.tooMany", max))
+		return
+	}
+
+	unit, ok := normalizeUnit(r.Form.Get("unit"))
+	if unit != "" && !ok {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("unit.invalid", r.Form.Get("unit")))
+		return
+	}
+	blockStr := r.Form.Get("block")
+	block, ok := parseBlockParam(blockStr)
+	if !ok {
+		cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("block.invalid", blockStr))
+		return
+	}
+	if blockStr == "" {
+		blockStr = "latest"
+	}
+
+	addresses := make([]common.Address, len(addressStrs))
+	for i, addressStr := range addressStrs {
+		address, err := parseAddress(addressStr, cli.networkID)
+		if err != nil {
+			cli.writeJSONError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		addresses[i] = address
+	}
+
+	balances, err := cli.getBalances(r.Context(), addresses, block)
+	if err != nil {
+		log.Printf("get balances error: %v", err)
+		cli.writeJSONError(w, r, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newBalanceResponse(blockStr, unit, addresses, balances))
+}
+
+// newBalanceResponse formats the balances of the addresses in the unit
+func newBalanceResponse(block, unit string, addresses []common.Address, balances []balanceResult) balanceResponse {
+	resp := balanceResponse{Block: block, Unit: unit, Balances: make([]addressBalance, len(addresses))}
+	for i, address := range addresses {
+		item := addressBalance{Address: address.Hex()}
+		if balances[i].err != nil {
+			item.Error = balances[i].err.Error()
+		} else {
+			amount := balances[i].wei.ToInt()
+			item.Wei = amount.String()
+			if unit != "" {
+				item.Balance = formatWei(amount, unit)
+			}
+			item.Text = formatAmount(amount, unit)
+		}
+		resp.Balances[i] = item
+	}
+	return resp
+}
+
+// balanceResult is the balance of an address, or the error of its call
+type balanceResult struct {
+	wei *hexutil.Big
+	err error
+}
+
+// getBalances gets the balances of the addresses at the block in one
+// JSON-RPC batch
+func (cli *CLI) getBalances(ctx context.Context, addresses []common.Address, block interface{}) ([]balanceResult, error) {
+	client, err := rpc.DialContext(ctx, cli.rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	results := make([]balanceResult, len(addresses))
+	batch := make([]rpc.BatchElem, len(addresses))
+	for i, address := range addresses {
+		results[i].wei = new(hexutil.Big)
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getBalance",
+			Args:   []interface{}{address, block},
+			Result: results[i].wei,
+		}
+	}
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		return nil, err
+	}
+	for i := range batch {
+		results[i].err = batch[i].Error
+	}
+
+	return results, nil
+}
+
+func (cli *CLI) buildBalanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "balance <address...> [--unit NEW] [--block latest] [--json]",
+		Short:                 "Get the balances of the addresses",
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			unitStr, _ := cmd.Flags().GetString("unit")
+			unit, ok := normalizeUnit(unitStr)
+			if unit != "" && !ok {
+				fmt.Printf("Unit(%s) for amount error. %s.\n", unitStr, DenominationString)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+			blockStr, _ := cmd.Flags().GetString("block")
+			block, ok := parseBlockParam(blockStr)
+			if !ok {
+				fmt.Printf("Error: invalid block %s\n", blockStr)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+
+			addresses := make([]common.Address, len(args))
+			for i, addressStr := range args {
+				address, err := parseAddress(addressStr, nil)
+				if err != nil {
+					fmt.Printf("Error: %s: %v\n", addressStr, err)
+					return
+				}
+				addresses[i] = address
+			}
+
+			balances, err := cli.getBalances(context.Background(), addresses, block)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			resp := newBalanceResponse(blockStr, unit, addresses, balances)
+
+			if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+				printJSON(resp)
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, b := range resp.Balances {
+				if b.Error != "" {
+					fmt.Fprintf(w, "%s\tError: %s\n", b.Address, b.Error)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\n", b.Address, b.Text)
+				}
+			}
+			w.Flush()
+		},
+	}
+
+	cmd.Flags().StringP("unit", "u", "", fmt.Sprintf("The `unit` of the balances, WEI or NEW by the amount if not set. %s.", DenominationString))
+	cmd.Flags().StringP("block", "b", "latest", "The `block`: latest, pending, earliest, a block number or a block hash")
+	cmd.Flags().Bool("json", false, "Print the result as JSON")
+
+	return cmd
+}
@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/viper"
+)
+
+func TestFormatWei(t *testing.T) {
+	tests := []struct {
+		wei  string
+		unit string
+		want string
+	}{
+		{"1500000000000000000", "NEW", "1.5"},
+		{"1", "NEW", "0.000000000000000001"},
+		{"0", "NEW", "0"},
+		{"1000000000", "GWEI", "1"},
+		{"1234567", "KWEI", "1234.567"},
+		{"123", "WEI", "123"},
+		{"-2500000000000000000", "NEW", "-2.5"},
+		{"1500000", "MWEI", "1.5"},
+		{"120000000000", "SZABO", "0.12"},
+		{"16888000000000000000000", "FINNEY", "16888000"},
+		{"7", "UNKNOWN", "7"},
+	}
+	for _, test := range tests {
+		amount, _ := new(big.Int).SetString(test.wei, 10)
+		if got := formatWei(amount, test.unit); got != test.want {
+			t.Errorf("(%s %s) want %s, got %s", test.wei, test.unit, test.want, got)
+		}
+	}
+}
+
+func TestParseBlockParam(t *testing.T) {
+	tests := []struct {
+		block string
+		want  interface{}
+		ok    bool
+	}{
+		{"", "latest", true},
+		{"pending", "pending", true},
+		{"100", "0x64", true},
+		{"0x64", "0x64", true},
+		{"010", "0xa", true},
+		{"0x", nil, false},
+		{"0b11", nil, false},
+		{"0o17", nil, false},
+		{"1_000", nil, false},
+		{"0x_64", nil, false},
+		{"0x" + "11" + "00000000000000000000000000000000000000000000000000000000000000", nil, true},
+		{"yesterday", nil, false},
+	}
+	for _, test := range tests {
+		got, ok := parseBlockParam(test.block)
+		if ok != test.ok {
+			t.Errorf("(%s) want ok %v, got %v", test.block, test.ok, ok)
+			continue
+		}
+		if test.want != nil && got != test.want {
+			t.Errorf("(%s) want %v, got %v", test.block, test.want, got)
+		}
+	}
+}
+
+func TestBalanceAPI(t *testing.T) {
+	var batchSize int
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			t.Errorf("not a batch: %v", err)
+			return
+		}
+		batchSize = len(reqs)
+		resps := make([]map[string]interface{}, len(reqs))
+		for i, req := range reqs {
+			resps[i] = map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0x14d1120d7b160000"} // 1.5 NEW
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer node.Close()
+
+	cli := newTestCLI()
+	cli.rpcURL = node.URL
+	viper.Set("faucet.maxBalanceAddresses", 2)
+	defer viper.Set("faucet.maxBalanceAddresses", nil)
+	var err error
+	if cli.catalogs, err = loadCatalogs(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/api/v1/balance?address=0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481,0x8709Fe1cB55C6aB630456C887af578e7bE9F7490&unit=gwei&block=100", nil)
+	w := httptest.NewRecorder()
+	cli.balanceAPIHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("wrong status %v: %s", w.Code, w.Body.String())
+	}
+	var resp balanceResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if batchSize != 2 || len(resp.Balances) != 2 {
+		t.Fatalf("wrong batch size %d or balances %d", batchSize, len(resp.Balances))
+	}
+	if b := resp.Balances[1]; b.Wei != "1500000000000000000" || b.Balance != "1500000000" || b.Text != "1500000000 GWEI" {
+		t.Errorf("wrong balance: %+v", b)
+	}
+
+	for _, url := range []string{
+		"/api/v1/balance",
+		"/api/v1/balance?address=0x1234",
+		"/api/v1/balance?address=0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481&address=0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481,0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481",
+		"/api/v1/balance?address=0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481&unit=BTC",
+		"/api/v1/balance?address=0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481&block=yesterday",
+	} {
+		w := httptest.NewRecorder()
+		cli.balanceAPIHandler(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("(%s) wrong status: want %v, got %v", url, http.StatusBadRequest, w.Code)
+		}
+	}
+
+	out := NewCLI().TestCommand("balance --rpcURL " + node.URL + " 0x8709Fe1cB55C6aB630456C887af578e7bE9F7490 --unit new")
+	if !strings.Contains(out, "0x8709Fe1cB55C6aB630456C887af578e7bE9F7490  1.5 NEW") {
+		t.Errorf("wrong balance command output: %s", out)
+	}
+}
+
+func TestGetBalanceHandler(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	if cli.catalogs, err = loadCatalogs(); err != nil {
+		t.Fatal(err)
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", stubBalance{}); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(server)
+	defer node.Close()
+	// a node without eth_getBalance
+	broken := httptest.NewServer(rpc.NewServer())
+	defer broken.Close()
+
+	for rpcURL, want := range map[string]string{
+		node.URL:   "balance: 1.5 NEW",
+		broken.URL: "something is wrong:",
+	} {
+		cli.rpcURL = rpcURL
+		w := httptest.NewRecorder()
+		cli.getBalanceHandler(w, httptest.NewRequest("GET", "/balance?address=0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481", nil))
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("(%s) %q not in the response: %s", rpcURL, want, w.Body.String())
+		}
+	}
+}
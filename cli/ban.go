@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+const defaultBansFile = "./bans.json"
+
+// ban is a banned address or IP. IP can be a single IP or a CIDR.
+type ban struct {
+	Address   *common.Address `json:"address,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	Reason    string          `json:"reason,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// banList keeps the bans in memory and persists them to a JSON file
+type banList struct {
+	path      string
+	mu        sync.Mutex
+	addresses map[common.Address]ban
+	ips       map[string]ban
+}
+
+// newBanList loads the bans from path if it exists
+func newBanList(path string) (*banList, error) {
+	b := &banList{
+		path:      path,
+		addresses: make(map[common.Address]ban),
+		ips:       make(map[string]ban),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var bans []ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, item := range bans {
+		if item.Address != nil {
+			b.addresses[*item.Address] = item
+		} else if item.IP != "" {
+			b.ips[item.IP] = item
+		}
+	}
+
+	return b, nil
+}
+
+// normalizeIP returns the canonical form of the IP or CIDR
+func normalizeIP(ip string) (string, error) {
+	if strings.Contains(ip, "/") {
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return "", err
+		}
+		return ipNet.String(), nil
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return "", fmt.Errorf("invalid IP %s", ip)
+	}
+	return parsed.String(), nil
+}
+
+// list returns all the bans ordered by creation time
+func (b *banList) list() []ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.listLocked()
+}
+
+func (b *banList) listLocked() []ban {
+	bans := make([]ban, 0, len(b.addresses)+len(b.ips))
+	for _, item := range b.addresses {
+		bans = append(bans, item)
+	}
+	for _, item := range b.ips {
+		bans = append(bans, item)
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].CreatedAt.Before(bans[j].CreatedAt) })
+
+	return bans
+}
+
+// add bans the address or the IP of item
+func (b *banList) add(item ban) error {
+	if item.Address == nil && item.IP == "" {
+		return fmt.Errorf("address or ip required")
+	}
+	if item.IP != "" {
+		ip, err := normalizeIP(item.IP)
+		if err != nil {
+			return err
+		}
+		item.IP = ip
+	}
+	item.CreatedAt = time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if item.Address != nil {
+		b.addresses[*item.Address] = item
+	} else {
+		b.ips[item.IP] = item
+	}
+	return writeJSONFile(b.path, b.listLocked())
+}
+
+// remove lifts the ban of the address or the IP, and reports whether it was banned
+func (b *banList) remove(address *common.Address, ip string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ok bool
+	if address != nil {
+		_, ok = b.addresses[*address]
+		delete(b.addresses, *address)
+	} else {
+		normalized, err := normalizeIP(ip)
+		if err != nil {
+			return false, err
+		}
+		_, ok = b.ips[normalized]
+		delete(b.ips, normalized)
+	}
+	if !ok {
+		return false, nil
+	}
+	return true, writeJSONFile(b.path, b.listLocked())
+}
+
+func (b *banList) isAddressBanned(address common.Address) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	_, ok := b.addresses[address]
+	return ok
+}
+
+func (b *banList) isIPBanned(ipStr string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for banned := range b.ips {
+		if !strings.Contains(banned, "/") {
+			if banned == ip.String() {
+				return true
+			}
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(banned); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the IP of the client. The X-Forwarded-For and X-Real-IP
+// headers are used only if `faucet.trustProxy` is set. The client can send
+// any X-Forwarded-For, so it is the rightmost entry not added by a trusted
+// proxy: the peer if `faucet.trustedProxies` is empty, or the IPs and the
+// CIDRs of the list.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !viper.GetBool("faucet.trustProxy") {
+		return host
+	}
+
+	proxies := viper.GetStringSlice("faucet.trustedProxies")
+	trusted := func(ip string, peer bool) bool {
+		if len(proxies) == 0 {
+			return peer
+		}
+		return ipInList(ip, proxies)
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" && trusted(host, true) {
+			return strings.TrimSpace(realIP)
+		}
+		return host
+	}
+
+	var hops []string
+	for _, value := range forwarded {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	hops = append(hops, host)
+	i := len(hops) - 1
+	for i > 0 && trusted(hops[i], i == len(hops)-1) {
+		i--
+	}
+	return hops[i]
+}
+
+// ipInList reports whether the IP is one of the IPs or in one of the CIDRs
+func ipInList(ipStr string, list []string) bool {
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range list {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		} else if other := net.ParseIP(entry); other != nil && other.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClient returns error if the client of the request is banned
+func (cli *CLI) checkClient(r *http.Request) error {
+	if cli.bans.isIPBanned(clientIP(r)) {
+		return newFaucetError("client.banned")
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/spf13/viper"
+)
+
+func TestBanList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	bans, err := newBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481")
+	if err := bans.add(ban{Address: &address, Reason: "abuse"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.add(ban{IP: "10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.add(ban{IP: "192.168.1.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bans.add(ban{IP: "not an ip"}); err == nil {
+		t.Errorf("invalid IP accepted")
+	}
+
+	// reload from file
+	bans, err = newBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bans.isAddressBanned(address) {
+		t.Errorf("address %s not banned", address.Hex())
+	}
+	for ip, want := range map[string]bool{"10.1.2.3": true, "192.168.1.1": true, "192.168.1.2": false, "": false} {
+		if got := bans.isIPBanned(ip); got != want {
+			t.Errorf("(%s) wrong ban: want %v, got %v", ip, want, got)
+		}
+	}
+
+	if ok, err := bans.remove(nil, "10.0.0.0/8"); !ok || err != nil {
+		t.Errorf("remove CIDR: %v %v", ok, err)
+	}
+	if bans.isIPBanned("10.1.2.3") {
+		t.Errorf("IP still banned after remove")
+	}
+	if ok, _ := bans.remove(nil, "10.0.0.0/8"); ok {
+		t.Errorf("removed twice")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer viper.Set("faucet.trustProxy", nil)
+	defer viper.Set("faucet.trustedProxies", nil)
+
+	for _, c := range []struct {
+		trustProxy bool
+		proxies    []string
+		forwarded  string
+		want       string
+	}{
+		{false, nil, "1.1.1.1", "10.0.0.1"},
+		{true, nil, "", "10.0.0.1"},
+		// the client spoofs the leftmost entry, the peer 10.0.0.1 is the proxy
+		{true, nil, "6.6.6.6, 1.1.1.1", "1.1.1.1"},
+		{true, []string{"10.0.0.0/8"}, "6.6.6.6, 1.1.1.1, 10.0.0.2", "1.1.1.1"},
+		{true, []string{"10.0.0.0/8", "2.2.2.2"}, "6.6.6.6, 1.1.1.1, 2.2.2.2", "1.1.1.1"},
+		// the peer is not a trusted proxy
+		{true, []string{"192.168.0.0/16"}, "1.1.1.1", "10.0.0.1"},
+	} {
+		viper.Set("faucet.trustProxy", c.trustProxy)
+		viper.Set("faucet.trustedProxies", c.proxies)
+		r := httptest.NewRequest("GET", "/faucet", nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		if got := clientIP(r); got != c.want {
+			t.Errorf("(%v, %v, %s): want %s, got %s", c.trustProxy, c.proxies, c.forwarded, c.want, got)
+		}
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io"
 	"math/big"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -31,8 +34,41 @@ type CLI struct {
 	networkID *big.Int
 	amountWei *big.Int
 	nonce     uint64
-	password  string
 	coinbase  string
+	signer    signer // signs the transactions of the faucet accounts
+
+	hdAccounts []*hdAccount // the funding accounts of the HD wallet, empty for the keystore
+	hdNext     int          // index of the next HD account to send from, guarded by sendMu
+
+	amount    string
+	unit      string
+	cooldown  time.Duration
+	budgetWei *big.Int // nil for unlimited
+
+	powDifficulty int    // leading zero bits of the proof of work, 0 for disabled
+	powSalt       string // changes on every start
+
+	catalogs map[string]messages // message catalogs keyed by language
+
+	tickets         *ticketStore
+	queue           chan string // IDs of the queued tickets
+	ticketRetention time.Duration
+	confirmations   uint64 // confirmations required for a confirmed request
+	events          *eventBus
+	stop            chan struct{} // closed on shutdown
+	senderDone      chan struct{} // closed when the ticket sender returns
+
+	sendMu    sync.Mutex // serializes payouts
+	mu        sync.Mutex // guards the payout settings, payouts and paused
+	payouts   *payoutLog
+	paused    bool
+	pauseCond *sync.Cond // signaled on resume
+	bans      *banList
+	startTime time.Time
+	audit     *auditLog
+	admin     *http.Server
+
+	lastErrors []adminError // guarded by mu, newest first
 }
 
 // NewCLI returns an initialized CLI
@@ -29,6 +29,16 @@ func (cli *CLI) buildRootCmd() {
 
 	// Core commands
 	rootCmd.AddCommand(cli.buildStartCmd()) // pay
+	rootCmd.AddCommand(cli.buildSendCmd())  // send
+
+	// Query commands
+	rootCmd.AddCommand(cli.buildBalanceCmd()) // balance
+	rootCmd.AddCommand(cli.buildReceiptCmd()) // receipt
+
+	// Admin commands
+	rootCmd.AddCommand(cli.buildStatusCmd()) // status
+	rootCmd.AddCommand(cli.buildPauseCmd())  // pause
+	rootCmd.AddCommand(cli.buildResumeCmd()) // resume
 
 	// Alias commands
 	rootCmd.AddCommand(cli.buildAccountCmd()) // account
@@ -1,14 +1,25 @@
 package cli
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/spf13/viper"
 )
 
 const defaultConfigFile = "./config.toml"
 const defaultWalletPath = "./wallet/"
 const defaultRPCURL = "https://rpc1.newchain.newtonproject.org"
+const defaultTicketsFile = "./tickets.json"
+const defaultClefEndpoint = "http://127.0.0.1:8550"
+
+// envPrefix is the prefix of the env vars of the config keys, e.g.
+// NEWCHAIN_FAUCET_FAUCET_FROM for faucet.from
+const envPrefix = "NEWCHAIN_FAUCET"
 
 func defaultConfig(cli *CLI) {
 	viper.BindPFlag("walletPath", cli.rootCmd.PersistentFlags().Lookup("walletPath"))
@@ -16,6 +27,41 @@ func defaultConfig(cli *CLI) {
 
 	viper.SetDefault("walletPath", defaultWalletPath)
 	viper.SetDefault("rpcURL", defaultRPCURL)
+	viper.SetDefault("faucet.signer", signerKeystore)
+	viper.SetDefault("faucet.passwordEnv", defaultPasswordEnv)
+	viper.SetDefault("faucet.ticketsFile", defaultTicketsFile)
+	viper.SetDefault("faucet.queueSize", 1000)
+	viper.SetDefault("faucet.ticketRetention", "24h")
+	viper.SetDefault("faucet.confirmations", 1)
+	viper.SetDefault("faucet.websocket", false)
+	viper.SetDefault("faucet.bansFile", defaultBansFile)
+	viper.SetDefault("faucet.payoutsFile", defaultPayoutsFile)
+	viper.SetDefault("faucet.readHeaderTimeout", "10s")
+	viper.SetDefault("faucet.readTimeout", "30s")
+	viper.SetDefault("faucet.writeTimeout", "60s")
+	viper.SetDefault("faucet.idleTimeout", "120s")
+	viper.SetDefault("faucet.maxHeaderBytes", 1<<16)
+	viper.SetDefault("faucet.maxBodyBytes", 1<<16)
+	viper.SetDefault("faucet.shutdownTimeout", "30s")
+	viper.SetDefault("faucet.maxBalanceAddresses", 20)
+	viper.SetDefault("faucet.middleware", defaultMiddleware)
+	viper.SetDefault("faucet.policies", defaultPolicies)
+	viper.SetDefault("faucet.rateLimitWindow", "1m")
+	viper.SetDefault("faucet.cors.methods", []string{"GET", "POST", "OPTIONS"})
+	viper.SetDefault("faucet.cors.headers", []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "Accept-Language"})
+	viper.SetDefault("faucet.cors.exposeHeaders", []string{"X-Request-ID", "Location", "Retry-After"})
+	viper.SetDefault("faucet.cors.maxAge", "10m")
+	viper.SetDefault("keystore.scryptN", keystore.StandardScryptN)
+	viper.SetDefault("keystore.scryptP", keystore.StandardScryptP)
+	viper.SetDefault("clef.endpoint", defaultClefEndpoint)
+	viper.SetDefault("vault.mode", vaultTransit)
+	viper.SetDefault("vault.appRoleMount", "approle")
+	viper.SetDefault("hd.file", defaultHDFile)
+	viper.SetDefault("hd.path", defaultHDPath)
+	viper.SetDefault("hd.accounts", 1)
+	viper.SetDefault("admin.auditLog", defaultAuditLog)
+	viper.SetDefault("web.enabled", true)
+	viper.SetDefault("web.title", "NewChain Faucet")
 }
 
 func setupConfig(cli *CLI) error {
@@ -25,9 +71,18 @@ func setupConfig(cli *CLI) error {
 
 	defaultConfig(cli)
 
+	// the env vars override the config file, the flags override the env vars
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+
 	viper.SetConfigName(defaultConfigFile)
 	viper.AddConfigPath(".")
 	cfgFile := cli.config
+	if config, ok := os.LookupEnv(envPrefix + "_CONFIG"); ok && !cli.rootCmd.PersistentFlags().Changed("config") {
+		cfgFile = config
+		cli.config = config
+	}
 	if cfgFile != "" {
 		if _, err = os.Stat(cfgFile); err == nil {
 			viper.SetConfigFile(cfgFile)
@@ -47,3 +102,100 @@ func setupConfig(cli *CLI) error {
 
 	return err
 }
+
+// setConfigValue sets the key "table.name" to the string value in the TOML
+// config file at path, creating the file if not exists. Only the line of the
+// key is edited, so the case of the keys and the comments are kept.
+func setConfigValue(path, key, value string) error {
+	if ext := filepath.Ext(path); ext != "" && ext != ".toml" {
+		return fmt.Errorf("only a TOML config can be edited, set %s to %q in %s", key, value, path)
+	}
+	i := strings.LastIndex(key, ".")
+	table, name := key[:i], key[i+1:]
+	line := name + " = " + strconv.Quote(value)
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+
+	// the table from its header to the next header
+	start, end := -1, len(lines)
+	for n, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if !strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		if start >= 0 {
+			end = n
+			break
+		}
+		if header, _, _ := strings.Cut(trimmed, "#"); strings.TrimSpace(header) == "["+table+"]" {
+			start = n
+		}
+	}
+	if start < 0 {
+		if len(lines) > 0 {
+			if strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+			lines = append(lines, "")
+		}
+		lines = append(lines, "["+table+"]", "  "+line, "")
+		return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	}
+
+	last := start
+	for n := start + 1; n < end; n++ {
+		l := strings.TrimSuffix(lines[n], "\r")
+		k, v, ok := strings.Cut(l, "=")
+		if !ok || strings.HasPrefix(strings.TrimSpace(k), "#") {
+			continue
+		}
+		last = n
+		if !strings.EqualFold(strings.TrimSpace(k), name) {
+			continue
+		}
+		// keep the indent, the key and the comment after the value
+		indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		comment := ""
+		if quoted := strings.TrimSpace(v); strings.HasPrefix(quoted, "\"") {
+			if j := strings.Index(quoted[1:], "\""); j >= 0 {
+				if _, c, ok := strings.Cut(quoted[j+2:], "#"); ok {
+					comment = " #" + c
+				}
+			}
+		}
+		lines[n] = indent + strings.TrimSpace(k) + " = " + strconv.Quote(value) + comment + lines[n][len(l):]
+		return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	}
+
+	// insert after the last key of the table with its indent
+	indent, eol := "  ", ""
+	if last > start {
+		l := lines[last]
+		indent = l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+	}
+	if strings.HasSuffix(lines[start], "\r") {
+		eol = "\r"
+	}
+	lines = append(lines[:last+1], append([]string{indent + line + eol}, lines[last+1:]...)...)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// scryptParams returns the scrypt N and P of `keystore.scryptN` and
+// `keystore.scryptP` for the new and re-encrypted keys
+func scryptParams() (int, int) {
+	return viper.GetInt("keystore.scryptN"), viper.GetInt("keystore.scryptP")
+}
+
+// newKeyStore returns the keystore of the wallet path, encrypting the keys
+// with the scrypt params of the config
+func newKeyStore(walletPath string) *keystore.KeyStore {
+	scryptN, scryptP := scryptParams()
+	return keystore.NewKeyStore(walletPath, scryptN, scryptP)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetConfigValue(t *testing.T) {
+	dir := t.TempDir()
+	for _, c := range []struct {
+		before, want string
+	}{
+		{
+			"",
+			"[faucet]\n  from = \"0x01\"\n",
+		},
+		{
+			"# the faucet\nrpcURL = \"http://localhost:8545\"\n",
+			"# the faucet\nrpcURL = \"http://localhost:8545\"\n\n[faucet]\n  from = \"0x01\"\n",
+		},
+		{
+			"[faucet]\n  # the funding account\n  from = \"0x00\" # set by import\n  passwordFile = \"./password.txt\"\n\n[faucet.cors]\n  from = \"0x00\"\n",
+			"[faucet]\n  # the funding account\n  from = \"0x01\" # set by import\n  passwordFile = \"./password.txt\"\n\n[faucet.cors]\n  from = \"0x00\"\n",
+		},
+		{
+			"[faucet] # the faucet\n    amount = \"1\"\n    unit = \"NEW\"\n\n[keystore]\n  scryptN = 262144\n",
+			"[faucet] # the faucet\n    amount = \"1\"\n    unit = \"NEW\"\n    from = \"0x01\"\n\n[keystore]\n  scryptN = 262144\n",
+		},
+		{
+			"[faucet]\r\n  From = \"0x00\"\r\n",
+			"[faucet]\r\n  From = \"0x01\"\r\n",
+		},
+	} {
+		path := filepath.Join(dir, "config.toml")
+		os.Remove(path)
+		if c.before != "" {
+			if err := os.WriteFile(path, []byte(c.before), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := setConfigValue(path, "faucet.from", "0x01"); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := os.ReadFile(path)
+		if string(got) != c.want {
+			t.Errorf("(%q) want\n%q, got\n%q", c.before, c.want, got)
+		}
+	}
+
+	if err := setConfigValue(filepath.Join(dir, "config.yaml"), "faucet.from", "0x01"); err == nil {
+		t.Error("YAML config edited")
+	}
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Types of the request events, in the order of the request progress
+const (
+	eventValidated    = "validated"
+	eventQueued       = "queued"
+	eventBroadcast    = "broadcast"
+	eventIncluded     = "included"
+	eventConfirmation = "confirmation"
+	eventConfirmed    = "confirmed"
+	eventFailed       = "failed"
+)
+
+const eventBufferSize = 64
+
+// requestEvent is the progress of a faucet request
+type requestEvent struct {
+	Type          string         `json:"type"`
+	TicketID      string         `json:"ticketId,omitempty"`
+	Address       common.Address `json:"address"`
+	TxHash        *common.Hash   `json:"txHash,omitempty"`
+	BlockNumber   *uint64        `json:"blockNumber,omitempty"`
+	Confirmations uint64         `json:"confirmations,omitempty"`
+	Required      uint64         `json:"required,omitempty"` // confirmations required
+	Error         string         `json:"error,omitempty"`
+	ErrorCode     string         `json:"errorCode,omitempty"`
+	ErrorArgs     []string       `json:"errorArgs,omitempty"`
+	Time          time.Time      `json:"time"`
+}
+
+// final reports whether no more events follow the event
+func (e requestEvent) final() bool {
+	return e.Type == eventConfirmed || e.Type == eventFailed
+}
+
+// withError sets the error of the event
+func (e requestEvent) withError(err error) requestEvent {
+	e.Error = err.Error()
+	e.ErrorCode, e.ErrorArgs = errorCode(err)
+	return e
+}
+
+// subscription receives the events of one ticket
+type subscription struct {
+	ticketID string
+	C        chan requestEvent
+}
+
+// eventBus delivers the request events to the subscriptions
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[*subscription]struct{})}
+}
+
+func (b *eventBus) subscribe(ticketID string) *subscription {
+	s := &subscription{
+		ticketID: ticketID,
+		C:        make(chan requestEvent, eventBufferSize),
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+
+	return s
+}
+
+func (b *eventBus) unsubscribe(s *subscription) {
+	b.mu.Lock()
+	delete(b.subs, s)
+	b.mu.Unlock()
+}
+
+// publish sends the event to the matched subscriptions without blocking
+func (b *eventBus) publish(e requestEvent) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs {
+		if s.ticketID != e.TicketID {
+			continue
+		}
+		select {
+		case s.C <- e:
+		default:
+			log.Printf("event %s of ticket %s dropped: subscriber too slow", e.Type, e.TicketID)
+		}
+	}
+}
+
+// ticketEvent returns the event of the current state of the ticket
+func (cli *CLI) ticketEvent(t ticket) requestEvent {
+	e := requestEvent{
+		TicketID:      t.ID,
+		Address:       t.Address,
+		TxHash:        t.TxHash,
+		BlockNumber:   t.BlockNumber,
+		Confirmations: t.Confirmations,
+		Required:      cli.confirmations,
+		Time:          t.UpdatedAt,
+	}
+
+	switch t.Status {
+	case ticketQueued, ticketSending:
+		e.Type = eventQueued
+	case ticketSent:
+		e.Type = eventBroadcast
+	case ticketMined:
+		e.Type = eventIncluded
+		if t.Confirmations >= cli.confirmations {
+			e.Type = eventConfirmed
+		} else if t.Confirmations > 1 {
+			e.Type = eventConfirmation
+		}
+	case ticketFailed:
+		e.Type = eventFailed
+		e.Error = t.Error
+		e.ErrorCode = t.ErrorCode
+		e.ErrorArgs = t.ErrorArgs
+	}
+
+	return e
+}
+
+// initialEvents returns the events a new stream starts with: validated, as
+// every ticket passed the validation before it was created, then the event
+// of the current state of the ticket
+func (cli *CLI) initialEvents(t ticket) []requestEvent {
+	validated := requestEvent{
+		Type:     eventValidated,
+		TicketID: t.ID,
+		Address:  t.Address,
+		Time:     t.CreatedAt,
+	}
+	return []requestEvent{validated, cli.ticketEvent(t)}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := newEventBus()
+	sub := bus.subscribe("ticket1")
+	other := bus.subscribe("ticket2")
+	defer bus.unsubscribe(other)
+
+	bus.publish(requestEvent{Type: eventQueued, TicketID: "ticket1"})
+	bus.publish(requestEvent{Type: eventFailed, TicketID: "ticket1"})
+
+	for _, want := range []string{eventQueued, eventFailed} {
+		select {
+		case e := <-sub.C:
+			if e.Type != want {
+				t.Errorf("wrong event: want %v, got %v", want, e.Type)
+			}
+			if e.Time.IsZero() {
+				t.Errorf("event time not set")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %s not received", want)
+		}
+	}
+	if len(other.C) != 0 {
+		t.Errorf("event of other ticket received")
+	}
+
+	bus.unsubscribe(sub)
+	bus.publish(requestEvent{Type: eventQueued, TicketID: "ticket1"})
+	if len(sub.C) != 0 {
+		t.Errorf("event received after unsubscribe")
+	}
+}
+
+func TestTicketEvent(t *testing.T) {
+	cli := newTestCLI()
+	cli.confirmations = 3
+	block := uint64(100)
+
+	tests := []struct {
+		ticket ticket
+		want   string
+	}{
+		{ticket{Status: ticketQueued}, eventQueued},
+		{ticket{Status: ticketSent}, eventBroadcast},
+		{ticket{Status: ticketMined, BlockNumber: &block, Confirmations: 1}, eventIncluded},
+		{ticket{Status: ticketMined, BlockNumber: &block, Confirmations: 2}, eventConfirmation},
+		{ticket{Status: ticketMined, BlockNumber: &block, Confirmations: 3}, eventConfirmed},
+		{ticket{Status: ticketFailed}, eventFailed},
+	}
+	for _, test := range tests {
+		if got := cli.ticketEvent(test.ticket).Type; got != test.want {
+			t.Errorf("(%s, %d) wrong event: want %v, got %v", test.ticket.Status, test.ticket.Confirmations, test.want, got)
+		}
+	}
+}
+
+func TestInitialEvents(t *testing.T) {
+	cli := newTestCLI()
+	cli.confirmations = 1
+	block := uint64(100)
+
+	tests := []struct {
+		ticket ticket
+		want   []string
+	}{
+		{ticket{Status: ticketQueued}, []string{eventValidated, eventQueued}},
+		{ticket{Status: ticketMined, BlockNumber: &block, Confirmations: 1}, []string{eventValidated, eventConfirmed}},
+		{ticket{Status: ticketFailed}, []string{eventValidated, eventFailed}},
+	}
+	for _, test := range tests {
+		events := cli.initialEvents(test.ticket)
+		if len(events) != len(test.want) {
+			t.Fatalf("(%s) wrong events: want %v, got %v", test.ticket.Status, test.want, events)
+		}
+		for i, e := range events {
+			if e.Type != test.want[i] {
+				t.Errorf("(%s) wrong event %d: want %v, got %v", test.ticket.Status, i, test.want[i], e.Type)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/viper"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// defaultDerivationPath is the BIP-44 path of the first account
+const defaultDerivationPath = "m/44'/60'/0'/0/0"
+
+// defaultHDPath is the BIP-44 path of the accounts of the HD wallet, without the index
+const defaultHDPath = "m/44'/60'/0'/0"
+
+const defaultHDFile = "./hdwallet.json"
+
+// hardenedKeyStart is the first index of the hardened keys of BIP-32
+const hardenedKeyStart = 0x80000000
+
+// seedFromMnemonic returns the BIP-39 seed of the mnemonic and the passphrase
+func seedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, fmt.Errorf("invalid mnemonic")
+	}
+	return bip39.NewSeed(mnemonic, passphrase), nil
+}
+
+// deriveKey derives the private key of the path from the seed by BIP-32
+func deriveKey(seed []byte, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	curveN := crypto.S256().Params().N
+
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode := new(big.Int).SetBytes(sum[:32]), sum[32:]
+	if key.Sign() == 0 || key.Cmp(curveN) >= 0 {
+		return nil, fmt.Errorf("invalid seed")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= hardenedKeyStart {
+			data = append([]byte{0}, keyBytes(key)...)
+		} else {
+			privateKey, err := crypto.ToECDSA(keyBytes(key))
+			if err != nil {
+				return nil, err
+			}
+			data = crypto.CompressPubkey(&privateKey.PublicKey)
+		}
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		tweak := new(big.Int).SetBytes(sum[:32])
+		if tweak.Cmp(curveN) >= 0 {
+			return nil, fmt.Errorf("invalid child key at %d, use the next index", index)
+		}
+		key = tweak.Add(tweak, key).Mod(tweak, curveN)
+		if key.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child key at %d, use the next index", index)
+		}
+		chainCode = sum[32:]
+	}
+
+	return crypto.ToECDSA(keyBytes(key))
+}
+
+// keyBytes returns the key as 32 bytes big-endian
+func keyBytes(key *big.Int) []byte {
+	return key.FillBytes(make([]byte, 32))
+}
+
+// hdWallet is the BIP-39 seed encrypted like a keystore file, with the
+// addresses derived from it so they are listed without the password
+type hdWallet struct {
+	Path      string              `json:"path"` // the account at index i is Path/i
+	Addresses []common.Address    `json:"addresses"`
+	Crypto    keystore.CryptoJSON `json:"crypto"`
+}
+
+// newHDWallet encrypts the seed with the password and derives n addresses
+func newHDWallet(seed []byte, path, password string, n, scryptN, scryptP int) (*hdWallet, error) {
+	if _, err := accounts.ParseDerivationPath(path); err != nil {
+		return nil, err
+	}
+	cryptoJSON, err := keystore.EncryptDataV3(seed, []byte(password), scryptN, scryptP)
+	if err != nil {
+		return nil, err
+	}
+	w := &hdWallet{Path: path, Crypto: cryptoJSON}
+	if err := w.deriveAddresses(seed, n); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func loadHDWallet(file string) (*hdWallet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var w hdWallet
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return &w, nil
+}
+
+func (w *hdWallet) save(file string) error {
+	return writeJSONFile(file, w)
+}
+
+// seed decrypts the seed with the password
+func (w *hdWallet) seed(password string) ([]byte, error) {
+	return keystore.DecryptDataV3(w.Crypto, password)
+}
+
+// derive returns the private key of the account at the index
+func (w *hdWallet) derive(seed []byte, index int) (*ecdsa.PrivateKey, error) {
+	path, err := accounts.ParseDerivationPath(fmt.Sprintf("%s/%d", w.Path, index))
+	if err != nil {
+		return nil, err
+	}
+	return deriveKey(seed, path)
+}
+
+// deriveAddresses derives the addresses up to n accounts
+func (w *hdWallet) deriveAddresses(seed []byte, n int) error {
+	for i := len(w.Addresses); i < n; i++ {
+		key, err := w.derive(seed, i)
+		if err != nil {
+			return err
+		}
+		w.Addresses = append(w.Addresses, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	return nil
+}
+
+// hdAccount is a funding account of the HD wallet
+type hdAccount struct {
+	address common.Address
+	key     *ecdsa.PrivateKey
+	nonce   *uint64 // &cli.nonce for the account 0
+}
+
+// unlockHDWallet decrypts the seed of `hd.file` and derives the first n
+// accounts for the payouts
+func (cli *CLI) unlockHDWallet(n int) ([]*hdAccount, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("Error: hd.accounts less than 1: %d", n)
+	}
+	file := viper.GetString("hd.file")
+	w, err := loadHDWallet(file)
+	if err != nil {
+		return nil, fmt.Errorf("Error: load HD wallet: %v", err)
+	}
+
+	var seed []byte
+	err = unlockWithPassword("HD wallet "+file, func(password string) error {
+		seed, err = w.seed(password)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hdAccounts := make([]*hdAccount, n)
+	for i := range hdAccounts {
+		key, err := w.derive(seed, i)
+		if err != nil {
+			return nil, err
+		}
+		hdAccounts[i] = &hdAccount{address: crypto.PubkeyToAddress(key.PublicKey), key: key, nonce: new(uint64)}
+	}
+	hdAccounts[0].nonce = &cli.nonce
+	for i := range seed {
+		seed[i] = 0
+	}
+
+	return hdAccounts, nil
+}
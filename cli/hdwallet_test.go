@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/viper"
+)
+
+func TestDeriveKey(t *testing.T) {
+	seed, err := seedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"m/44'/60'/0'/0/0": "0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+		"m/44'/60'/0'/0/1": "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0",
+	} {
+		derivationPath, err := accounts.ParseDerivationPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := deriveKey(seed, derivationPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address := crypto.PubkeyToAddress(key.PublicKey).Hex(); address != want {
+			t.Errorf("%s: want %s, got %s", path, want, address)
+		}
+	}
+
+	if _, err := seedFromMnemonic("abandon abandon", ""); err == nil {
+		t.Error("invalid mnemonic accepted")
+	}
+}
+
+func TestHDWallet(t *testing.T) {
+	seed, _ := seedFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	w, err := newHDWallet(seed, defaultHDPath, "pw", 2, keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "hdwallet.json")
+	if err := w.save(file); err != nil {
+		t.Fatal(err)
+	}
+	if w, err = loadHDWallet(file); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Addresses) != 2 || w.Addresses[1].Hex() != "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0" {
+		t.Fatalf("wrong addresses: %v", w.Addresses)
+	}
+	if _, err := w.seed("wrong"); err == nil {
+		t.Error("wrong password accepted")
+	}
+
+	viper.Set("hd.file", file)
+	viper.Set("faucet.password", "pw")
+	defer viper.Set("hd.file", nil)
+	defer viper.Set("faucet.password", nil)
+	cli := newTestCLI()
+	cli.networkID = big.NewInt(1007)
+	if cli.hdAccounts, err = cli.unlockHDWallet(2); err != nil {
+		t.Fatal(err)
+	}
+	cli.signer = hdSigner(cli.hdAccounts)
+
+	// the payouts rotate over the accounts, the account 0 with cli.nonce
+	cli.nonce = 7
+	for i, want := range []int{0, 1, 0} {
+		from, nonce := cli.nextSender()
+		if from != w.Addresses[want] {
+			t.Errorf("payout %d: want account %d, got %s", i, want, from.Hex())
+		}
+		if want == 0 && *nonce != 7 {
+			t.Errorf("payout %d: wrong nonce %d", i, *nonce)
+		}
+
+		tx := types.NewTransaction(*nonce, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		signed, err := cli.signTx(from, tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sender, err := types.Sender(types.NewEIP155Signer(cli.networkID), signed); err != nil || sender != from {
+			t.Errorf("payout %d: wrong sender %s (%v)", i, sender.Hex(), err)
+		}
+	}
+}
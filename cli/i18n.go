@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const defaultLanguage = "en"
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// messages is a message catalog of one language, keyed by message ID
+type messages map[string]string
+
+// defaultMessages is the built-in English catalog, also used by faucetError.Error
+var defaultMessages = mustLoadBuiltinCatalog(defaultLanguage)
+
+func mustLoadBuiltinCatalog(lang string) messages {
+	data, err := localesFS.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		panic(err)
+	}
+	var m messages
+	if err := json.Unmarshal(data, &m); err != nil {
+		panic(fmt.Errorf("locales/%s.json: %v", lang, err))
+	}
+	return m
+}
+
+// faucetError is an error shown to the user, translated by its message ID
+type faucetError struct {
+	id   string
+	args []interface{}
+}
+
+func newFaucetError(id string, args ...interface{}) error {
+	return &faucetError{id: id, args: args}
+}
+
+func (e *faucetError) Error() string {
+	return fmt.Sprintf(defaultMessages[e.id], e.args...)
+}
+
+// errorCode returns the message ID and the formatted arguments if err is a faucetError
+func errorCode(err error) (string, []string) {
+	var fe *faucetError
+	if !errors.As(err, &fe) {
+		return "", nil
+	}
+	args := make([]string, len(fe.args))
+	for i, arg := range fe.args {
+		args[i] = fmt.Sprint(arg)
+	}
+	return fe.id, args
+}
+
+// loadCatalogs returns the built-in catalogs merged with the catalogs
+// `<lang>.json` in the directory `i18n.dir`.
+func loadCatalogs() (map[string]messages, error) {
+	catalogs := make(map[string]messages)
+
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		lang := strings.TrimSuffix(entry.Name(), ".json")
+		catalogs[lang] = mustLoadBuiltinCatalog(lang)
+	}
+
+	dir := viper.GetString("i18n.dir")
+	if dir == "" {
+		return catalogs, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var m messages
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+
+		lang := strings.TrimSuffix(filepath.Base(file), ".json")
+		if catalogs[lang] == nil {
+			catalogs[lang] = make(messages)
+		}
+		for id, text := range m {
+			catalogs[lang][id] = text
+		}
+	}
+
+	return catalogs, nil
+}
+
+// printer formats messages in one language, falling back to English
+type printer struct {
+	lang    string
+	catalog messages
+}
+
+// Sprintf formats the message id with args
+func (p printer) Sprintf(id string, args ...interface{}) string {
+	text, ok := p.catalog[id]
+	if !ok {
+		text = defaultMessages[id]
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// Error translates faucetError, or returns the text of other errors
+func (p printer) Error(err error) string {
+	var fe *faucetError
+	if errors.As(err, &fe) {
+		return p.Sprintf(fe.id, fe.args...)
+	}
+	return err.Error()
+}
+
+// Messages returns all messages of the language
+func (p printer) Messages() messages {
+	m := make(messages, len(defaultMessages))
+	for id, text := range defaultMessages {
+		m[id] = text
+	}
+	for id, text := range p.catalog {
+		m[id] = text
+	}
+	return m
+}
+
+// printer returns the printer for the `lang` parameter or
+// the Accept-Language header of the request.
+func (cli *CLI) printer(r *http.Request) printer {
+	var candidates []string
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		candidates = append(candidates, lang)
+	}
+	candidates = append(candidates, parseAcceptLanguage(r.Header.Get("Accept-Language"))...)
+	candidates = append(candidates, viper.GetString("i18n.default"), defaultLanguage)
+
+	for _, tag := range candidates {
+		if lang, ok := cli.matchLanguage(tag); ok {
+			return printer{lang: lang, catalog: cli.catalogs[lang]}
+		}
+	}
+
+	return printer{lang: defaultLanguage, catalog: defaultMessages}
+}
+
+// matchLanguage matches the tag exactly, or by the primary language
+// subtag, e.g. both "zh-cn" and "zh" match "zh-CN".
+func (cli *CLI) matchLanguage(tag string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	langs := make([]string, 0, len(cli.catalogs))
+	for lang := range cli.catalogs {
+		if strings.EqualFold(lang, tag) {
+			return lang, true
+		}
+		langs = append(langs, lang)
+	}
+
+	sort.Strings(langs)
+	primary := strings.SplitN(tag, "-", 2)[0]
+	for _, lang := range langs {
+		if strings.EqualFold(strings.SplitN(lang, "-", 2)[0], primary) {
+			return lang, true
+		}
+	}
+
+	return "", false
+}
+
+// parseAcceptLanguage returns the language tags of the header ordered by quality
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: strings.ReplaceAll(tag, "_", "-"), q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
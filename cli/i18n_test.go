@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	cli := newTestCLI()
+	catalogs, err := loadCatalogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.catalogs = catalogs
+
+	tests := []struct {
+		url            string
+		acceptLanguage string
+		want           string
+	}{
+		{"/faucet", "", "en"},
+		{"/faucet", "zh-CN,zh;q=0.9,en;q=0.8", "zh-CN"},
+		{"/faucet", "en;q=0.5,zh-TW;q=0.8", "zh-CN"},
+		{"/faucet", "fr", "en"},
+		{"/faucet?lang=zh", "en", "zh-CN"},
+		{"/faucet?lang=en", "zh-CN", "en"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.url, nil)
+		r.Header.Set("Accept-Language", test.acceptLanguage)
+		if got := cli.printer(r).lang; got != test.want {
+			t.Errorf("(%s, %s) wrong language: want %v, got %v", test.url, test.acceptLanguage, test.want, got)
+		}
+	}
+
+	for lang, catalog := range catalogs {
+		for id := range defaultMessages {
+			if _, ok := catalog[id]; !ok {
+				t.Errorf("message %s missing in catalog %s", id, lang)
+			}
+		}
+	}
+
+	p := printer{lang: "zh-CN", catalog: catalogs["zh-CN"]}
+	if got := p.Error(newFaucetError("pow.required")); got != catalogs["zh-CN"]["pow.required"] {
+		t.Errorf("wrong translation: got %v", got)
+	}
+}
@@ -2,9 +2,10 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -12,20 +13,35 @@ import (
 
 func (cli *CLI) buildInitCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:                   "init",
+		Use:                   "init [--non-interactive [--newAccount --passwordFile file] [--force]] [--format toml|yaml|json]",
 		Short:                 "Initialize config file",
 		DisableFlagsInUseLine: true,
 		Run: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.Flags().GetString("format")
+
+			if nonInteractive, _ := cmd.Flags().GetBool("non-interactive"); nonInteractive {
+				cli.initNonInteractive(cmd, format)
+				return
+			}
 
 			fmt.Println("Initialize config file")
 
-			promptStr := fmt.Sprintf("Enter file in which to save (%s): ", defaultConfigFile)
+			defaultPath, err := configFileFormat(defaultConfigFile, format)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+			promptStr := fmt.Sprintf("Enter file in which to save (%s): ", defaultPath)
 			configPath, err := prompt.Stdin.PromptInput(promptStr)
 			if err != nil {
 				fmt.Println("PromptInput err:", err)
 			}
 			if configPath == "" {
-				configPath = defaultConfigFile
+				configPath = defaultPath
+			}
+			if configPath, err = configFileFormat(configPath, format); err != nil {
+				fmt.Println("Error:", err)
+				return
 			}
 			cli.config = configPath
 
@@ -60,8 +76,7 @@ func (cli *CLI) buildInitCmd() *cobra.Command {
 				createNewAddress = "Y"
 			}
 			if strings.ToUpper(createNewAddress[:1]) == "Y" {
-				wallet := keystore.NewKeyStore(walletPath,
-					keystore.LightScryptN, keystore.LightScryptP)
+				wallet := newKeyStore(walletPath)
 
 				walletPassword, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true)
 				if err == nil {
@@ -88,6 +103,87 @@ func (cli *CLI) buildInitCmd() *cobra.Command {
 			}
 		},
 	}
+	cmd.Flags().Bool("non-interactive", false, "Write the config of the flags and the env vars without prompts")
+	cmd.Flags().String("format", "", "The `format` of the config file, toml, yaml or json (default by the file extension)")
+	cmd.Flags().Bool("newAccount", false, "Create a new account as faucet.from, with --non-interactive")
+	cmd.Flags().String("passwordFile", "", "The `file` of the password of the new account, set as faucet.passwordFile")
+	cmd.Flags().Bool("force", false, "Overwrite the config file if exists, with --non-interactive")
 
 	return cmd
 }
+
+// initNonInteractive writes the config file of --config, --walletPath and
+// --rpcURL, or their env vars, and creates the account if --newAccount
+func (cli *CLI) initNonInteractive(cmd *cobra.Command, format string) {
+	configPath, err := configFileFormat(cli.config, format)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if newAccount, _ := cmd.Flags().GetBool("newAccount"); newAccount {
+		passwordFile, _ := cmd.Flags().GetString("passwordFile")
+		if passwordFile == "" {
+			fmt.Println("Error: --passwordFile required for --newAccount")
+			fmt.Fprint(os.Stderr, cmd.UsageString())
+			return
+		}
+		password, err := readPasswordFile(passwordFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		if password == "" {
+			fmt.Println("Error: empty password in", passwordFile)
+			return
+		}
+
+		account, err := newKeyStore(viper.GetString("walletPath")).NewAccount(password)
+		if err != nil {
+			fmt.Println("Account error:", err)
+			return
+		}
+		fmt.Println(account.Address.String())
+		viper.Set("faucet.from", account.Address.String())
+		viper.Set("faucet.passwordFile", passwordFile)
+	}
+
+	if force, _ := cmd.Flags().GetBool("force"); force {
+		err = viper.WriteConfigAs(configPath)
+	} else {
+		err = viper.SafeWriteConfigAs(configPath)
+	}
+	if err != nil {
+		fmt.Println("WriteConfig:", err)
+		return
+	}
+	fmt.Println("Your configuration has been saved in ", configPath)
+}
+
+// configFormats are the config formats by the file extension
+var configFormats = map[string]string{"toml": "toml", "yaml": "yaml", "yml": "yaml", "json": "json"}
+
+// configFileFormat returns the path of the config file in the format, toml
+// by default. The extension of the default path, or of the path without
+// extension, is set by the format. Other paths must have the extension of
+// the format.
+func configFileFormat(path, format string) (string, error) {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if format == "" {
+		if ext == "" {
+			return path + ".toml", nil
+		}
+		format = ext
+	}
+	want, ok := configFormats[format]
+	if !ok {
+		return "", fmt.Errorf("unsupported config format %q, use toml, yaml or json", format)
+	}
+
+	switch {
+	case path == defaultConfigFile || ext == "":
+		return strings.TrimSuffix(path, filepath.Ext(path)) + "." + format, nil
+	case configFormats[ext] != want:
+		return "", fmt.Errorf("the extension of %s does not match the format %s", path, format)
+	}
+	return path, nil
+}
@@ -1,9 +1,55 @@
 package cli
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
 
 func TestInit(t *testing.T) {
 	cli := NewCLI()
 
 	cli.TestCommand("init")
 }
+
+func TestConfigFileFormat(t *testing.T) {
+	for _, c := range []struct {
+		path, format, want string
+	}{
+		{defaultConfigFile, "", defaultConfigFile},
+		{defaultConfigFile, "json", "./config.json"},
+		{"faucet.yml", "yaml", "faucet.yml"},
+		{"faucet", "", "faucet.toml"},
+		{"faucet", "yaml", "faucet.yaml"},
+		{"faucet.json", "toml", ""},
+		{"faucet.ini", "", ""},
+		{defaultConfigFile, "ini", ""},
+	} {
+		path, err := configFileFormat(c.path, c.format)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("(%s, %s) accepted", c.path, c.format)
+			}
+			continue
+		}
+		if err != nil || path != c.want {
+			t.Errorf("(%s, %s): want %s, got %s (%v)", c.path, c.format, c.want, path, err)
+		}
+	}
+}
+
+func TestConfigEnv(t *testing.T) {
+	t.Setenv("NEWCHAIN_FAUCET_FAUCET_AMOUNT", "5")
+	t.Setenv("NEWCHAIN_FAUCET_HD_ACCOUNTS", "3")
+
+	cli := NewCLI()
+	if err := setupConfig(cli); err != nil {
+		t.Fatal(err)
+	}
+	if amount := viper.GetString("faucet.amount"); amount != "5" {
+		t.Errorf("faucet.amount: want 5, got %s", amount)
+	}
+	if n := viper.GetInt("hd.accounts"); n != 3 {
+		t.Errorf("hd.accounts: want 3, got %d", n)
+	}
+}
@@ -0,0 +1,405 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// middleware wraps a handler with a policy or a feature of the server
+type middleware func(http.Handler) http.Handler
+
+// Default middleware chains, the first is the outermost. The middleware
+// apply to all routes, the policies only to the routes paying out money.
+var (
+	defaultMiddleware = []string{"requestID", "logging", "recovery", "cors"}
+	defaultPolicies   = []string{"auth", "rateLimit", "eligibility"}
+)
+
+// chain wraps h with the middleware in order, so mws[0] runs first
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// buildMiddleware returns the middleware of the names in `faucet.middleware`
+// or `faucet.policies`
+func (cli *CLI) buildMiddleware(names []string) ([]middleware, error) {
+	mws := make([]middleware, 0, len(names))
+	for _, name := range names {
+		var mw middleware
+		switch name {
+		case "requestID":
+			mw = requestIDMiddleware
+		case "logging":
+			mw = loggingMiddleware
+		case "recovery":
+			mw = recoveryMiddleware
+		case "cors":
+			c := corsConfigFromViper()
+			if err := c.validate(); err != nil {
+				return nil, err
+			}
+			mw = corsMiddleware(c)
+		case "auth":
+			mw = cli.authMiddleware(viper.GetStringSlice("faucet.apiKeys"))
+		case "rateLimit":
+			limit := viper.GetInt("faucet.rateLimit")
+			window := viper.GetDuration("faucet.rateLimitWindow")
+			if limit < 0 || (limit > 0 && window <= 0) {
+				return nil, fmt.Errorf("invalid rate limit %d per %v", limit, window)
+			}
+			mw = cli.rateLimitMiddleware(newRateLimiter(limit, window))
+		case "eligibility":
+			mw = cli.eligibilityMiddleware
+		default:
+			return nil, fmt.Errorf("unknown middleware %q", name)
+		}
+		mws = append(mws, mw)
+	}
+	return mws, nil
+}
+
+// writePolicyError writes the error in JSON for the API, or in text for the
+// legacy routes
+func (cli *CLI) writePolicyError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		cli.writeJSONError(w, r, status, err)
+		return
+	}
+	p := cli.printer(r)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, p.Sprintf("faucet.error", p.Error(err)))
+}
+
+type requestIDKey struct{}
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID returns the ID of the request set by requestIDMiddleware
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware uses the X-Request-ID header of the client if valid,
+// or a new ID, and returns it in the response header
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			id = newTicketID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// statusRecorder records the status and the size of the response. It keeps
+// the streaming and the WebSocket working through the wrapped writer.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if s.status == 0 {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return http.NewResponseController(s.ResponseWriter).Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// loggingMiddleware logs each request after it is served
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			log.Printf("%s %s %d %dB %v %s id=%s", r.Method, r.URL.Path, rec.status, rec.size,
+				time.Since(start).Round(time.Millisecond), clientIP(r), requestID(r.Context()))
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// recoveryMiddleware turns a panic of the handler into 500
+func recoveryMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			log.Printf("panic serving %s %s id=%s: %v\n%s", r.Method, r.URL.Path, requestID(r.Context()), err, debug.Stack())
+			writeJSON(w, http.StatusInternalServerError, apiError{Error: http.StatusText(http.StatusInternalServerError)})
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsConfig is the CORS policy of `faucet.cors`
+type corsConfig struct {
+	Origins       []string // "*" for all, or patterns like "https://*.example.com"
+	Methods       []string
+	Headers       []string
+	ExposeHeaders []string
+	Credentials   bool
+	MaxAge        time.Duration
+}
+
+func corsConfigFromViper() corsConfig {
+	return corsConfig{
+		Origins:       viper.GetStringSlice("faucet.cors.origins"),
+		Methods:       viper.GetStringSlice("faucet.cors.methods"),
+		Headers:       viper.GetStringSlice("faucet.cors.headers"),
+		ExposeHeaders: viper.GetStringSlice("faucet.cors.exposeHeaders"),
+		Credentials:   viper.GetBool("faucet.cors.credentials"),
+		MaxAge:        viper.GetDuration("faucet.cors.maxAge"),
+	}
+}
+
+// wildcardMatch reports whether s matches the pattern, where each "*"
+// matches any string
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+// validate rejects the credentials for all origins, which would let any site
+// call the faucet with the cookies of the user
+func (c corsConfig) validate() error {
+	if !c.Credentials {
+		return nil
+	}
+	for _, pattern := range c.Origins {
+		if pattern == "*" {
+			return fmt.Errorf("cors.credentials not allowed with the origin \"*\"")
+		}
+	}
+	return nil
+}
+
+func (c corsConfig) allowOrigin(origin string) bool {
+	for _, pattern := range c.Origins {
+		if strings.EqualFold(pattern, origin) || wildcardMatch(strings.ToLower(pattern), strings.ToLower(origin)) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsMiddleware allows the origins of the config to call the faucet, and
+// answers the preflight requests of all routes
+func corsMiddleware(c corsConfig) middleware {
+	methods := strings.Join(c.Methods, ", ")
+	headers := strings.Join(c.Headers, ", ")
+	exposeHeaders := strings.Join(c.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(c.MaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if c.allowOrigin(origin) {
+				h.Set("Access-Control-Allow-Origin", origin)
+				if c.Credentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					h.Set("Access-Control-Allow-Methods", methods)
+					h.Set("Access-Control-Allow-Headers", headers)
+					if c.MaxAge > 0 {
+						h.Set("Access-Control-Max-Age", maxAge)
+					}
+				} else if exposeHeaders != "" {
+					h.Set("Access-Control-Expose-Headers", exposeHeaders)
+				}
+			}
+
+			// the browser checks the headers of the preflight response
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authMiddleware requires one of the API keys in the header
+// `Authorization: Bearer <key>` or `X-API-Key`. No key is required if
+// keys is empty.
+func (cli *CLI) authMiddleware(keys []string) middleware {
+	return func(next http.Handler) http.Handler {
+		if len(keys) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := bearerToken(r)
+			if !ok {
+				got = r.Header.Get("X-API-Key")
+			}
+			for _, key := range keys {
+				if got != "" && subtle.ConstantTimeCompare([]byte(got), []byte(key)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			cli.writePolicyError(w, r, http.StatusUnauthorized, newFaucetError("auth.required"))
+		})
+	}
+}
+
+// rateLimiter limits the requests of each client in a fixed window
+type rateLimiter struct {
+	limit  int // 0 for no limit
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		window:  window,
+		clients: make(map[string]*rateWindow),
+	}
+}
+
+// allow counts the request of the client, and returns the time to wait if
+// the client exceeds the limit
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	if l.limit == 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// forget the clients of the past windows
+	if now.Sub(l.lastSweep) > l.window {
+		for key, w := range l.clients {
+			if now.Sub(w.start) >= l.window {
+				delete(l.clients, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.clients[client]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.clients[client] = w
+	}
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// rateLimitMiddleware limits the requests of each client IP
+func (cli *CLI) rateLimitMiddleware(limiter *rateLimiter) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, wait := limiter.allow(clientIP(r), time.Now())
+			if !ok {
+				seconds := int((wait + time.Second - 1) / time.Second)
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				cli.writePolicyError(w, r, http.StatusTooManyRequests,
+					newFaucetError("rateLimit.exceeded", time.Duration(seconds)*time.Second))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// eligibilityMiddleware rejects the banned clients
+func (cli *CLI) eligibilityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := cli.checkClient(r); err != nil {
+			cli.writePolicyError(w, r, http.StatusForbidden, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
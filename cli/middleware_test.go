@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), mw("a"), mw("b"))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if got := strings.Join(order, ","); got != "a,b,handler" {
+		t.Errorf("wrong order: %s", got)
+	}
+}
+
+func TestRequestIDAndRecovery(t *testing.T) {
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), requestIDMiddleware, loggingMiddleware, recoveryMiddleware)
+
+	r := httptest.NewRequest("GET", "/api/v1/info", nil)
+	r.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("wrong status: want %v, got %v", http.StatusInternalServerError, w.Code)
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Errorf("wrong request ID: %s", got)
+	}
+
+	r = httptest.NewRequest("GET", "/api/v1/info", nil)
+	r.Header.Set("X-Request-ID", "bad id\n")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("X-Request-ID"); got == "" || got == "bad id\n" {
+		t.Errorf("invalid request ID kept: %q", got)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	if cli.catalogs, err = loadCatalogs(); err != nil {
+		t.Fatal(err)
+	}
+	if cli.bans, err = newBanList(filepath.Join(t.TempDir(), "bans.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.bans.add(ban{IP: "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), cli.authMiddleware([]string{"key"}), cli.rateLimitMiddleware(newRateLimiter(2, time.Minute)), cli.eligibilityMiddleware)
+
+	tests := []struct {
+		key    string
+		remote string
+		want   int
+	}{
+		{"", "192.0.2.1:1000", http.StatusUnauthorized},
+		{"key", "192.0.2.1:1000", http.StatusNoContent},
+		{"key", "192.0.2.1:1000", http.StatusNoContent},
+		{"key", "192.0.2.1:1000", http.StatusTooManyRequests},
+		{"key", "10.0.0.1:1000", http.StatusForbidden},
+	}
+	for i, test := range tests {
+		r := httptest.NewRequest("POST", "/api/v1/faucet", nil)
+		r.RemoteAddr = test.remote
+		if test.key != "" {
+			r.Header.Set("X-API-Key", test.key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.want {
+			t.Errorf("(%d) wrong status: want %v, got %v: %s", i, test.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAuthMiddlewareHeaders(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	if cli.catalogs, err = loadCatalogs(); err != nil {
+		t.Fatal(err)
+	}
+	h := cli.authMiddleware([]string{"key"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for header, want := range map[[2]string]int{
+		{"Authorization", "Bearer key"}: http.StatusNoContent,
+		{"Authorization", "bearer key"}: http.StatusNoContent,
+		{"Authorization", "Bearer bad"}: http.StatusUnauthorized,
+		{"Authorization", "key"}:        http.StatusUnauthorized,
+		{"X-API-Key", "key"}:            http.StatusNoContent,
+	} {
+		r := httptest.NewRequest("POST", "/api/v1/faucet", nil)
+		r.Header.Set(header[0], header[1])
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("(%s: %s) wrong status: want %v, got %v", header[0], header[1], want, w.Code)
+		}
+	}
+}
+
+func TestBansWithoutEligibility(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	if cli.catalogs, err = loadCatalogs(); err != nil {
+		t.Fatal(err)
+	}
+	if cli.bans, err = newBanList(filepath.Join(t.TempDir(), "bans.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.bans.add(ban{IP: "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("faucet.policies", []string{"rateLimit"})
+	defer viper.Set("faucet.policies", nil)
+
+	h, err := cli.newRouter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "/api/v1/faucet", nil)
+	r.RemoteAddr = "10.0.0.1:1000"
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("banned client not rejected: %v %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(1, time.Minute)
+	now := time.Now()
+	if ok, _ := l.allow("a", now); !ok {
+		t.Errorf("first request limited")
+	}
+	if ok, wait := l.allow("a", now.Add(10*time.Second)); ok || wait != 50*time.Second {
+		t.Errorf("wrong limit: %v %v", ok, wait)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Errorf("other client limited")
+	}
+	if ok, _ := l.allow("a", now.Add(time.Minute)); !ok {
+		t.Errorf("request of the next window limited")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := corsMiddleware(corsConfig{
+		Origins:     []string{"https://*.example.com", "http://localhost:3000"},
+		Methods:     []string{"GET", "POST"},
+		Headers:     []string{"Content-Type"},
+		Credentials: true,
+		MaxAge:      10 * time.Minute,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		method, origin string
+		preflight      bool
+		wantStatus     int
+		wantOrigin     string
+	}{
+		{"POST", "https://dapp.example.com", true, http.StatusNoContent, "https://dapp.example.com"},
+		{"POST", "https://example.com", true, http.StatusNoContent, ""},
+		{"GET", "http://localhost:3000", false, http.StatusOK, "http://localhost:3000"},
+		{"GET", "https://evil.com", false, http.StatusOK, ""},
+		{"OPTIONS", "https://dapp.example.com", false, http.StatusOK, "https://dapp.example.com"},
+	}
+	for _, test := range tests {
+		method := test.method
+		if test.preflight {
+			method = http.MethodOptions
+		}
+		r := httptest.NewRequest(method, "/faucet", nil)
+		r.Header.Set("Origin", test.origin)
+		if test.preflight {
+			r.Header.Set("Access-Control-Request-Method", test.method)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != test.wantStatus {
+			t.Errorf("(%s %s) wrong status: want %v, got %v", method, test.origin, test.wantStatus, w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != test.wantOrigin {
+			t.Errorf("(%s %s) wrong allowed origin: want %q, got %q", method, test.origin, test.wantOrigin, got)
+		}
+		if test.preflight && test.wantOrigin != "" {
+			if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("wrong max age: %s", got)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Errorf("credentials not allowed: %s", got)
+			}
+		}
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	if err := (corsConfig{Origins: []string{"*"}, Credentials: true}).validate(); err == nil {
+		t.Error("credentials allowed for all origins")
+	}
+	for _, c := range []corsConfig{
+		{Origins: []string{"*"}},
+		{Origins: []string{"https://*.example.com"}, Credentials: true},
+	} {
+		if err := c.validate(); err != nil {
+			t.Errorf("%+v rejected: %v", c, err)
+		}
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"*", "https://a.com", true},
+		{"https://*.example.com", "https://a.b.example.com", true},
+		{"https://*.example.com", "https://example.com", false},
+		{"https://*.example.com", "https://a.example.com.evil.com", false},
+		{"http://localhost:*", "http://localhost:8080", true},
+		{"https://a.com", "https://a.com", true},
+	} {
+		if got := wildcardMatch(test.pattern, test.s); got != test.want {
+			t.Errorf("(%s, %s) want %v, got %v", test.pattern, test.s, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const defaultPasswordEnv = "NEWCHAIN_FAUCET_PASSWORD"
+
+const passwordCommandTimeout = 30 * time.Second
+
+// configuredPassword returns the password of the first source set of
+// the env var `faucet.passwordEnv`, `faucet.passwordFile`,
+// `faucet.passwordCommand` and `faucet.password`, with the name of the
+// source. It returns an empty source if none is set.
+func configuredPassword() (string, string, error) {
+	if env := viper.GetString("faucet.passwordEnv"); env != "" {
+		if password, ok := os.LookupEnv(env); ok {
+			// not inherited by the password command or other children
+			os.Unsetenv(env)
+			return password, "the env var " + env, nil
+		}
+	}
+	if file := viper.GetString("faucet.passwordFile"); file != "" {
+		password, err := readPasswordFile(file)
+		return password, "the file " + file, err
+	}
+	if command := viper.GetString("faucet.passwordCommand"); command != "" {
+		password, err := runPasswordCommand(command)
+		return password, "the `faucet.passwordCommand`", err
+	}
+	if password := viper.GetString("faucet.password"); password != "" {
+		log.Printf("Warning: the plain text faucet.password is deprecated, use faucet.passwordFile, faucet.passwordCommand or the env var %s", defaultPasswordEnv)
+		return password, "the `faucet.password` in the config file", nil
+	}
+	return "", "", nil
+}
+
+// readPasswordFile reads the password in the first line of the file, which
+// must not be accessible by the group and others
+func readPasswordFile(file string) (string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("permissions %v of the password file %s are too open, run chmod 600 %s", info.Mode().Perm(), file, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return firstLine(string(data)), nil
+}
+
+// runPasswordCommand runs the command, split by spaces without a shell, and
+// returns the first line of its stdout as the password
+func runPasswordCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty password command")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), passwordCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("password command %s: %v", args[0], err)
+	}
+	return firstLine(string(out)), nil
+}
+
+// firstLine returns s up to the first line break
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// unlockWithPassword calls unlock with the configured password, or with the
+// password prompted up to 3 times if not configured. The password is not
+// kept after unlock, and `faucet.password` is cleared.
+func unlockWithPassword(name string, unlock func(password string) error) error {
+	password, source, err := configuredPassword()
+	if err != nil {
+		return fmt.Errorf("Error: Failed to read the password of %s (%v)", name, err)
+	}
+	if source != "" {
+		fmt.Printf("Unlocking %s\nUse the password of %s\n", name, source)
+		if err := unlock(password); err != nil {
+			return fmt.Errorf("Error: Failed to unlock %s (%v)", name, err)
+		}
+		// the plain text password is not kept in the config either
+		viper.Set("faucet.password", "")
+		return nil
+	}
+
+	for trials := 0; trials < 3; trials++ {
+		prompt := fmt.Sprintf("Unlocking %s | Attempt %d/%d", name, trials+1, 3)
+		password, _ = getPassPhrase(prompt, false)
+		err = unlock(password)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Error: Failed to unlock %s (%v)", name, err)
+}
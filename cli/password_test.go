@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestConfiguredPassword(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no file permissions or echo command")
+	}
+	file := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(file, []byte("secret\nignored\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPasswordFile(file); err == nil {
+		t.Error("password file readable by others accepted")
+	}
+	os.Chmod(file, 0600)
+	if password, err := readPasswordFile(file); err != nil || password != "secret" {
+		t.Errorf("wrong password %q (%v)", password, err)
+	}
+	if password, err := runPasswordCommand("echo from command"); err != nil || password != "from command" {
+		t.Errorf("wrong password %q (%v)", password, err)
+	}
+	if _, err := runPasswordCommand("false"); err == nil {
+		t.Error("failed password command accepted")
+	}
+
+	viper.Set("faucet.passwordEnv", "TEST_FAUCET_PASSWORD")
+	viper.Set("faucet.passwordFile", file)
+	viper.Set("faucet.password", "plain")
+	defer viper.Set("faucet.passwordEnv", nil)
+	defer viper.Set("faucet.passwordFile", nil)
+	defer viper.Set("faucet.password", nil)
+
+	// the env var first and only once, then the file
+	os.Setenv("TEST_FAUCET_PASSWORD", "from env")
+	for _, want := range []string{"from env", "secret"} {
+		password, source, err := configuredPassword()
+		if err != nil || password != want {
+			t.Errorf("want %q, got %q of %s (%v)", want, password, source, err)
+		}
+	}
+	if _, ok := os.LookupEnv("TEST_FAUCET_PASSWORD"); ok {
+		t.Error("env var not unset")
+	}
+
+	// the plain text password is cleared after unlock
+	viper.Set("faucet.passwordFile", nil)
+	var got string
+	if err := unlockWithPassword("test", func(password string) error { got = password; return nil }); err != nil || got != "plain" {
+		t.Errorf("want plain, got %q (%v)", got, err)
+	}
+	if password := viper.GetString("faucet.password"); password != "" {
+		t.Errorf("password %q kept", password)
+	}
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const defaultPayoutsFile = "./payouts.json"
+
+// payoutState is the file format of the payout log
+type payoutState struct {
+	Spent    string                       `json:"spent"` // wei
+	LastSent map[common.Address]time.Time `json:"lastSent"`
+}
+
+// payoutLog keeps the amount paid out and the last payout time of each
+// address for the budget and the cooldown, and persists them to a JSON file
+// so that a restart does not reset them. It is guarded by cli.mu.
+type payoutLog struct {
+	path     string
+	spentWei *big.Int
+	lastSent map[common.Address]time.Time
+}
+
+// newPayoutLog loads the payout log from path if it exists
+func newPayoutLog(path string) (*payoutLog, error) {
+	p := &payoutLog{
+		path:     path,
+		spentWei: new(big.Int),
+		lastSent: make(map[common.Address]time.Time),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state payoutState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if state.Spent != "" {
+		if _, ok := p.spentWei.SetString(state.Spent, 10); !ok {
+			return nil, fmt.Errorf("%s: invalid spent %q", path, state.Spent)
+		}
+	}
+	for address, t := range state.LastSent {
+		p.lastSent[address] = t
+	}
+
+	return p, nil
+}
+
+// record adds the payout and saves the log. The payouts older than the
+// cooldown are dropped as they no longer limit the address.
+func (p *payoutLog) record(toAddress common.Address, amount *big.Int, cooldown time.Duration) error {
+	now := time.Now()
+	p.lastSent[toAddress] = now
+	p.spentWei.Add(p.spentWei, amount)
+	if cooldown > 0 {
+		for address, t := range p.lastSent {
+			if now.Sub(t) >= cooldown {
+				delete(p.lastSent, address)
+			}
+		}
+	}
+
+	return writeJSONFile(p.path, payoutState{Spent: p.spentWei.String(), LastSent: p.lastSent})
+}
+
+// checkPayout returns error if the address is not allowed to get money now
+func (cli *CLI) checkPayout(toAddress common.Address) error {
+	if cli.bans.isAddressBanned(toAddress) {
+		return newFaucetError("address.banned", toAddress.Hex())
+	}
+
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	if cli.paused {
+		return newFaucetError("faucet.paused")
+	}
+	if cli.cooldown > 0 {
+		if last, ok := cli.payouts.lastSent[toAddress]; ok {
+			if wait := cli.cooldown - time.Since(last); wait > 0 {
+				return newFaucetError("payout.cooldown", toAddress.Hex(), wait.Round(time.Second))
+			}
+		}
+	}
+
+	if remaining := cli.remainingBudget(); remaining != nil && remaining.Cmp(cli.amountWei) < 0 {
+		return newFaucetError("payout.budgetExhausted")
+	}
+
+	return nil
+}
+
+// recordPayout records the payout for cooldown and budget
+func (cli *CLI) recordPayout(toAddress common.Address) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+
+	if err := cli.payouts.record(toAddress, cli.amountWei, cli.cooldown); err != nil {
+		log.Printf("save payouts error: %v", err)
+	}
+}
+
+// remainingBudget returns nil if the budget is unlimited. The caller must hold cli.mu.
+func (cli *CLI) remainingBudget() *big.Int {
+	if cli.budgetWei == nil {
+		return nil
+	}
+	remaining := new(big.Int).Sub(cli.budgetWei, cli.payouts.spentWei)
+	if remaining.Sign() < 0 {
+		remaining.SetInt64(0)
+	}
+	return remaining
+}
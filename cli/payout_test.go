@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPayoutLog(t *testing.T) {
+	cli := newTestCLI()
+	cli.bans, _ = newBanList(filepath.Join(t.TempDir(), "bans.json"))
+	cli.amount, cli.unit = "1", "NEW"
+	cli.amountWei, _ = getAmountWei(cli.amount, cli.unit)
+	cli.cooldown = time.Hour
+	cli.budgetWei, _ = getAmountWei("1.5", cli.unit)
+	path := filepath.Join(t.TempDir(), "payouts.json")
+	var err error
+	if cli.payouts, err = newPayoutLog(path); err != nil {
+		t.Fatal(err)
+	}
+
+	address := common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481")
+	other := common.HexToAddress("0x8709Fe1cB55C6aB630456C887af578e7bE9F7490")
+	if err := cli.checkPayout(address); err != nil {
+		t.Fatal(err)
+	}
+	cli.recordPayout(address)
+
+	// the cooldown and the budget survive a restart
+	if cli.payouts, err = newPayoutLog(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.checkPayout(address); err == nil || err.(*faucetError).id != "payout.cooldown" {
+		t.Errorf("cooldown not enforced: %v", err)
+	}
+	if err := cli.checkPayout(other); err == nil || err.(*faucetError).id != "payout.budgetExhausted" {
+		t.Errorf("budget not enforced: %v", err)
+	}
+
+	// the payouts older than the cooldown are dropped
+	cli.payouts.lastSent[address] = time.Now().Add(-2 * time.Hour)
+	cli.budgetWei = nil
+	cli.recordPayout(other)
+	if cli.payouts, err = newPayoutLog(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cli.payouts.lastSent[address]; ok || len(cli.payouts.lastSent) != 1 {
+		t.Errorf("wrong last payouts: %v", cli.payouts.lastSent)
+	}
+	if cli.payouts.spentWei.String() != "2000000000000000000" {
+		t.Errorf("wrong spent: %v", cli.payouts.spentWei)
+	}
+}
@@ -0,0 +1,214 @@
+//go:build pkcs11
+
+package cli
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/pkcs11"
+)
+
+// the DER encoded OID 1.3.132.0.10 of secp256k1
+var secp256k1OID = []byte{0x06, 0x05, 0x2b, 0x81, 0x04, 0x00, 0x0a}
+
+// pkcs11Signer signs with the secp256k1 key in a PKCS#11 token, the key
+// never leaves the token
+type pkcs11Signer struct {
+	ctx     *pkcs11.Ctx
+	address common.Address
+	pub     *ecdsa.PublicKey
+
+	// the session is not safe for concurrent use
+	mu      sync.Mutex
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+}
+
+// newPKCS11Signer loads the module, logs in to the token with the PIN of
+// the password sources, and finds the key pair by the label
+func newPKCS11Signer(module, token, label string) (*pkcs11Signer, error) {
+	if module == "" || label == "" {
+		return nil, fmt.Errorf("Error: pkcs11.module and pkcs11.label required")
+	}
+	ctx := pkcs11.New(module)
+	if ctx == nil {
+		return nil, fmt.Errorf("Error: load the PKCS#11 module %s failed", module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("Error: PKCS#11: %v", err)
+	}
+	s := &pkcs11Signer{ctx: ctx}
+
+	slot, err := findSlot(ctx, token)
+	if err == nil {
+		s.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	}
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("Error: PKCS#11: %v", err)
+	}
+
+	err = unlockWithPassword(fmt.Sprintf("the PKCS#11 token %q", token), func(pin string) error {
+		return ctx.Login(s.session, pkcs11.CKU_USER, pin)
+	})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	if err := s.findKey(label); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("Error: PKCS#11: %v", err)
+	}
+	return s, nil
+}
+
+// findKey finds the private key and the public key of the label
+func (s *pkcs11Signer) findKey(label string) error {
+	var err error
+	s.key, err = s.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return err
+	}
+	pubKey, err := s.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return err
+	}
+	s.pub, err = s.publicKey(pubKey)
+	if err != nil {
+		return fmt.Errorf("key %q: %v", label, err)
+	}
+	s.address = crypto.PubkeyToAddress(*s.pub)
+
+	return nil
+}
+
+// findSlot returns the slot of the token label, or the only slot with a
+// token if the label is empty
+func findSlot(ctx *pkcs11.Ctx, token string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	if token == "" {
+		if len(slots) != 1 {
+			return 0, fmt.Errorf("pkcs11.token required for %d tokens", len(slots))
+		}
+		return slots[0], nil
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimSpace(info.Label) == token {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("token %q not found", token)
+}
+
+// findObject returns the only object of the class with the label
+func (s *pkcs11Signer) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return 0, err
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 2)
+	s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return 0, err
+	}
+
+	kind := "private"
+	if class == pkcs11.CKO_PUBLIC_KEY {
+		kind = "public"
+	}
+	switch len(objects) {
+	case 0:
+		return 0, fmt.Errorf("EC %s key %q not found", kind, label)
+	case 1:
+		return objects[0], nil
+	default:
+		return 0, fmt.Errorf("more than one EC %s key %q", kind, label)
+	}
+}
+
+// publicKey reads the secp256k1 public key of the object
+func (s *pkcs11Signer) publicKey(object pkcs11.ObjectHandle) (*ecdsa.PublicKey, error) {
+	attrs, err := s.ctx.GetAttributeValue(s.session, object, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(attrs[0].Value, secp256k1OID) {
+		return nil, fmt.Errorf("not a secp256k1 key")
+	}
+
+	// the point is a DER octet string, but some tokens return it raw
+	point := attrs[1].Value
+	if len(point) != 65 {
+		var raw []byte
+		if _, err := asn1.Unmarshal(point, &raw); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %v", err)
+		}
+		point = raw
+	}
+	return crypto.UnmarshalPubkey(point)
+}
+
+// sign signs the hash with CKM_ECDSA, which returns r || s
+func (s *pkcs11Signer) sign(hash []byte) (*big.Int, *big.Int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}
+	if err := s.ctx.SignInit(s.session, mechanism, s.key); err != nil {
+		return nil, nil, err
+	}
+	sig, err := s.ctx.Sign(s.session, hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sig) != 64 {
+		return nil, nil, fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	return new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]), nil
+}
+
+func (s *pkcs11Signer) Address() common.Address {
+	return s.address
+}
+
+func (s *pkcs11Signer) SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if from != s.address {
+		return nil, fmt.Errorf("%s is not the PKCS#11 key %s", from.Hex(), s.address.Hex())
+	}
+	return signTxWith(tx, chainID, s.pub, s.sign)
+}
+
+// Close logs out and unloads the module
+func (s *pkcs11Signer) Close() {
+	if s.session != 0 {
+		s.ctx.Logout(s.session)
+		s.ctx.CloseSession(s.session)
+	}
+	s.ctx.Finalize()
+	s.ctx.Destroy()
+}
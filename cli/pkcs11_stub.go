@@ -0,0 +1,28 @@
+//go:build !pkcs11
+
+package cli
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// pkcs11Signer is not available without the pkcs11 build tag, which needs cgo
+type pkcs11Signer struct{}
+
+func newPKCS11Signer(module, token, label string) (*pkcs11Signer, error) {
+	return nil, fmt.Errorf("Error: PKCS#11 not supported by this build, build with -tags pkcs11")
+}
+
+func (s *pkcs11Signer) Address() common.Address {
+	return common.Address{}
+}
+
+func (s *pkcs11Signer) SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return nil, fmt.Errorf("PKCS#11 not supported by this build")
+}
+
+func (s *pkcs11Signer) Close() {}
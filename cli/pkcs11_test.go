@@ -0,0 +1,43 @@
+//go:build pkcs11
+
+package cli
+
+import (
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/spf13/viper"
+)
+
+// TestPKCS11Signer signs with a secp256k1 key in SoftHSM, e.g.
+//
+//	softhsm2-util --init-token --free --label faucet --pin 1234 --so-pin 1234
+//	pkcs11-tool --module $MODULE --login --pin 1234 --keypairgen --key-type EC:secp256k1 --label faucet
+//	PKCS11_MODULE=$MODULE PKCS11_PIN=1234 go test -tags pkcs11 -run PKCS11 ./cli
+func TestPKCS11Signer(t *testing.T) {
+	module := os.Getenv("PKCS11_MODULE")
+	if module == "" {
+		t.Skip("PKCS11_MODULE not set")
+	}
+	viper.Set("faucet.password", os.Getenv("PKCS11_PIN"))
+	defer viper.Set("faucet.password", nil)
+
+	s, err := newPKCS11Signer(module, "faucet", "faucet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	chainID := big.NewInt(1007)
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := s.SignTx(s.Address(), tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender, err := types.Sender(types.NewEIP155Signer(chainID), signed); err != nil || sender != s.Address() {
+		t.Errorf("wrong sender %s (%v)", sender.Hex(), err)
+	}
+}
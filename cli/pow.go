@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func newPoWSalt() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// checkPoW verifies the proof of work of the address if it is enabled.
+// The sha256 of "salt:address:pow" must have powDifficulty leading zero bits,
+// where address is the lower case hex address with 0x prefix.
+func (cli *CLI) checkPoW(address common.Address, pow string) error {
+	if cli.powDifficulty <= 0 {
+		return nil
+	}
+	if pow == "" {
+		return newFaucetError("pow.required")
+	}
+
+	h := sha256.Sum256([]byte(cli.powSalt + ":" + strings.ToLower(address.Hex()) + ":" + pow))
+	if leadingZeroBits(h[:]) < cli.powDifficulty {
+		return newFaucetError("pow.invalid")
+	}
+
+	return nil
+}
+
+func leadingZeroBits(b []byte) int {
+	var n int
+	for _, v := range b {
+		if v != 0 {
+			return n + bits.LeadingZeros8(v)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestPoW(t *testing.T) {
+	cli := newTestCLI()
+	cli.powSalt = newPoWSalt()
+	address := common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481")
+
+	if err := cli.checkPoW(address, ""); err != nil {
+		t.Errorf("proof of work required when disabled: %v", err)
+	}
+
+	cli.powDifficulty = 8
+	if err := cli.checkPoW(address, ""); err == nil {
+		t.Errorf("empty proof of work accepted")
+	}
+
+	for i := 0; i < 1<<16; i++ {
+		if cli.checkPoW(address, strconv.Itoa(i)) == nil {
+			return
+		}
+	}
+	t.Errorf("no proof of work found for difficulty %d", cli.powDifficulty)
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+)
+
+func (cli *CLI) buildReceiptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "receipt <txhash> [--json]",
+		Short:                 "Get the receipt of the transaction",
+		Args:                  cobra.ExactArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			hashBytes, err := hexutil.Decode(args[0])
+			if err != nil || len(hashBytes) != common.HashLength {
+				fmt.Println("Error: invalid transaction hash", args[0])
+				return
+			}
+			hash := common.BytesToHash(hashBytes)
+
+			client, err := ethclient.Dial(cli.rpcURL)
+			if err != nil {
+				fmt.Println("Dial error:", err)
+				return
+			}
+			defer client.Close()
+			ctx := context.Background()
+
+			receipt, err := client.TransactionReceipt(ctx, hash)
+			if err == ethereum.NotFound {
+				if _, pending, err := client.TransactionByHash(ctx, hash); err == nil && pending {
+					fmt.Println("Transaction pending:", hash.Hex())
+				} else {
+					fmt.Println("Error: transaction not found:", hash.Hex())
+				}
+				return
+			}
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+				printJSON(receipt)
+				return
+			}
+			printReceipt(receipt)
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "Print the receipt as JSON")
+
+	return cmd
+}
+
+// receiptStatus returns the decoded status of the receipt
+func receiptStatus(receipt *types.Receipt) string {
+	if receipt.Status == types.ReceiptStatusFailed {
+		return "failed"
+	}
+	return "success"
+}
+
+// printReceipt prints the receipt with the decoded status and its logs
+func printReceipt(receipt *types.Receipt) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Transaction:\t%s\n", receipt.TxHash.Hex())
+	fmt.Fprintf(w, "Status:\t%s\n", receiptStatus(receipt))
+	fmt.Fprintf(w, "Block:\t%v (%s)\n", receipt.BlockNumber, receipt.BlockHash.Hex())
+	fmt.Fprintf(w, "Index:\t%d\n", receipt.TransactionIndex)
+	fmt.Fprintf(w, "Gas used:\t%d\n", receipt.GasUsed)
+	fmt.Fprintf(w, "Cumulative gas used:\t%d\n", receipt.CumulativeGasUsed)
+	if receipt.ContractAddress != (common.Address{}) {
+		fmt.Fprintf(w, "Contract:\t%s\n", receipt.ContractAddress.Hex())
+	}
+	fmt.Fprintf(w, "Logs:\t%d\n", len(receipt.Logs))
+	w.Flush()
+
+	for _, l := range receipt.Logs {
+		fmt.Printf("\nLog %d\n", l.Index)
+		fmt.Println("  Address:", l.Address.Hex())
+		for i, topic := range l.Topics {
+			fmt.Printf("  Topic %d: %s\n", i, topic.Hex())
+		}
+		fmt.Println("  Data:", hexutil.Encode(l.Data))
+	}
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/viper"
+)
+
+// stubReceiptEth is the eth API of a node with the receipts of the mined
+// transactions and the pending transactions
+type stubReceiptEth struct {
+	receipts map[common.Hash]*types.Receipt
+	pending  map[common.Hash]*types.Transaction
+}
+
+func (s *stubReceiptEth) GetTransactionReceipt(hash common.Hash) *types.Receipt {
+	return s.receipts[hash]
+}
+
+func (s *stubReceiptEth) GetTransactionByHash(hash common.Hash) *types.Transaction {
+	return s.pending[hash]
+}
+
+func TestReceipt(t *testing.T) {
+	success := common.HexToHash("0x01")
+	failed := common.HexToHash("0x02")
+	key, _ := crypto.GenerateKey()
+	pending, err := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil), types.NewEIP155Signer(big.NewInt(1007)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eth := &stubReceiptEth{
+		receipts: map[common.Hash]*types.Receipt{
+			success: {
+				Status: types.ReceiptStatusSuccessful, TxHash: success, BlockNumber: big.NewInt(100), GasUsed: 21000, CumulativeGasUsed: 42000,
+				Logs: []*types.Log{{Address: common.HexToAddress("0x8709Fe1cB55C6aB630456C887af578e7bE9F7490"), Topics: []common.Hash{common.HexToHash("0xff")}, Data: []byte{1}}},
+			},
+			failed: {Status: types.ReceiptStatusFailed, TxHash: failed, BlockNumber: big.NewInt(101), GasUsed: 30000, CumulativeGasUsed: 30000, Logs: []*types.Log{}},
+		},
+		pending: map[common.Hash]*types.Transaction{pending.Hash(): pending},
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(server)
+	defer node.Close()
+	viper.Set("rpcURL", node.URL)
+	defer viper.Set("rpcURL", nil)
+
+	for _, test := range []struct {
+		hash string
+		want []string
+	}{
+		{success.Hex(), []string{`Status:\s+success`, `Block:\s+100 `, `Gas used:\s+21000`, `Logs:\s+1`, `Address: 0x8709Fe1cB55C6aB630456C887af578e7bE9F7490`, `Data: 0x01`}},
+		{failed.Hex(), []string{`Status:\s+failed`, `Block:\s+101 `, `Gas used:\s+30000`, `Logs:\s+0`}},
+		{pending.Hash().Hex(), []string{`Transaction pending: ` + pending.Hash().Hex()}},
+		{common.HexToHash("0x03").Hex(), []string{`Error: transaction not found`}},
+		{"0x1234", []string{`Error: invalid transaction hash`}},
+	} {
+		out := NewCLI().TestCommand("receipt " + test.hash)
+		for _, want := range test.want {
+			if !regexp.MustCompile(want).MatchString(out) {
+				t.Errorf("(%s) %q not in the output:\n%s", test.hash, want, out)
+			}
+		}
+	}
+
+	r := &types.Receipt{Status: types.ReceiptStatusFailed}
+	if got := receiptStatus(r); got != "failed" {
+		t.Errorf("want failed, got %s", got)
+	}
+	r.Status = types.ReceiptStatusSuccessful
+	if got := receiptStatus(r); got != "success" {
+		t.Errorf("want success, got %s", got)
+	}
+}
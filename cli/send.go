@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/cobra"
+)
+
+// promptConfirm asks the user to confirm the transaction, replaced by the tests
+var promptConfirm = prompt.Stdin.PromptConfirm
+
+func (cli *CLI) buildSendCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "send <address> [--amount 16888] [--unit NEW] [--from address] [-y] [--wait]",
+		Short:                 "Send money to the address without the server",
+		Args:                  cobra.ExactArgs(1),
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			unit, ok := normalizeUnit(flagOrConfig(cmd, "unit", "faucet.unit"))
+			if !ok {
+				fmt.Printf("Unit(%s) for amount error. %s.\n", unit, DenominationString)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+			amountWei, err := getAmountWei(flagOrConfig(cmd, "amount", "faucet.amount"), unit)
+			if err != nil {
+				fmt.Println("Get amount error:", err)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+			if amountWei.Sign() <= 0 {
+				fmt.Println("Error: amount must be greater than 0")
+				return
+			}
+
+			client, err := ethclient.Dial(cli.rpcURL)
+			if err != nil {
+				fmt.Println("Dial error:", err)
+				return
+			}
+			defer client.Close()
+			ctx := context.Background()
+
+			networkID, err := client.NetworkID(ctx)
+			if err != nil {
+				fmt.Println("Get NetworkID Error: ", err)
+				return
+			}
+			cli.networkID = networkID
+
+			toAddress, err := parseAddress(args[0], networkID)
+			if err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			from, err := cli.unlockSender(flagOrConfig(cmd, "from", "faucet.from"))
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			cli.coinbase = from.Hex()
+			// do not replace the pending transactions of the account
+			cli.nonce, err = client.PendingNonceAt(ctx, from)
+			if err != nil {
+				fmt.Println("PendingNonceAt error:", err)
+				return
+			}
+
+			tx := cli.newTransaction(ctx, client, from, &cli.nonce, toAddress, amountWei)
+			fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "From:\t%s\n", from.Hex())
+			fmt.Fprintf(w, "To:\t%s\n", toAddress.Hex())
+			fmt.Fprintf(w, "Amount:\t%s\n", formatAmount(amountWei, unit))
+			fmt.Fprintf(w, "Nonce:\t%d\n", tx.Nonce())
+			fmt.Fprintf(w, "Gas limit:\t%d\n", tx.Gas())
+			fmt.Fprintf(w, "Gas price:\t%s\n", formatAmount(tx.GasPrice(), "GWEI"))
+			fmt.Fprintf(w, "Max fee:\t%s\n", formatAmount(fee, ""))
+			fmt.Fprintf(w, "Total:\t%s\n", formatAmount(new(big.Int).Add(amountWei, fee), unit))
+			w.Flush()
+
+			if yes, _ := cmd.Flags().GetBool("yes"); !yes {
+				confirmed, err := promptConfirm("Send the transaction?")
+				if err != nil || !confirmed {
+					fmt.Println("Canceled")
+					return
+				}
+			}
+
+			signTx, err := cli.signTx(from, tx)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			if err := client.SendTransaction(ctx, signTx); err != nil {
+				fmt.Println("SendTransaction error:", err)
+				return
+			}
+			fmt.Println("Transaction hash:", signTx.Hash().Hex())
+
+			if wait, _ := cmd.Flags().GetBool("wait"); !wait {
+				return
+			}
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			waitCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			fmt.Println("Waiting for the receipt...")
+			receipt, err := bind.WaitMined(waitCtx, client, signTx)
+			if err != nil {
+				fmt.Println("Wait for the receipt error:", err)
+				return
+			}
+			fmt.Printf("Transaction %s in block %v, gas used %d\n", receiptStatus(receipt), receipt.BlockNumber, receipt.GasUsed)
+		},
+	}
+
+	cmd.Flags().String("from", "", "The source `address` (default faucet.from in the config file, or the account 0 of the HD wallet)")
+	cmd.Flags().StringP("amount", "a", "", "The `amount` to send (default faucet.amount in the config file)")
+	cmd.Flags().StringP("unit", "u", "", fmt.Sprintf("The `unit` of the amount (default faucet.unit in the config file). %s.", DenominationString))
+	cmd.Flags().BoolP("yes", "y", false, "Send without confirmation")
+	cmd.Flags().Bool("wait", false, "Wait for the receipt of the transaction")
+	cmd.Flags().Duration("timeout", 5*time.Minute, "The `duration` to wait for the receipt")
+
+	return cmd
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/spf13/viper"
+)
+
+// stubNet is the net API of the chain 1007
+type stubNet struct{}
+
+func (stubNet) Version() string {
+	return "1007"
+}
+
+// stubSendEth is the eth API of a node with the latest nonce 2 and the
+// pending nonce 3 of all accounts, keeping the sent transactions
+type stubSendEth struct {
+	mu   sync.Mutex
+	sent []*types.Transaction
+}
+
+func (s *stubSendEth) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(2000000000))
+}
+
+func (s *stubSendEth) EstimateGas(args map[string]interface{}) hexutil.Uint64 {
+	return 21000
+}
+
+func (s *stubSendEth) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	if block == "pending" {
+		return 3
+	}
+	return 2
+}
+
+func (s *stubSendEth) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		return common.Hash{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, tx)
+	return tx.Hash(), nil
+}
+
+// answer returns the prompt answering the confirmations with confirm
+func answer(confirm bool) func(string) (bool, error) {
+	return func(string) (bool, error) {
+		return confirm, nil
+	}
+}
+
+func TestSend(t *testing.T) {
+	eth := &stubSendEth{}
+	server := rpc.NewServer()
+	if err := server.RegisterName("net", stubNet{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(server)
+	defer node.Close()
+
+	walletPath := t.TempDir()
+	key, _ := crypto.GenerateKey()
+	account, err := keystore.NewKeyStore(walletPath, keystore.LightScryptN, keystore.LightScryptP).ImportECDSA(key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// set over the keys set by the init test
+	viper.Set("rpcURL", node.URL)
+	viper.Set("walletPath", walletPath)
+	defer viper.Set("rpcURL", nil)
+	defer viper.Set("walletPath", nil)
+	send := "send --from " + account.Address.Hex() + " --amount 1 --unit NEW 0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"
+
+	// declined
+	defer func(p func(string) (bool, error)) { promptConfirm = p }(promptConfirm)
+	promptConfirm = answer(false)
+	t.Setenv(defaultPasswordEnv, "secret")
+	out := NewCLI().TestCommand(send)
+	if !strings.Contains(out, "Canceled") || len(eth.sent) != 0 {
+		t.Fatalf("declined transaction sent: %d\n%s", len(eth.sent), out)
+	}
+
+	// the summary, then the transaction signed with the pending nonce
+	promptConfirm = answer(true)
+	t.Setenv(defaultPasswordEnv, "secret")
+	out = NewCLI().TestCommand(send)
+	for _, want := range []string{
+		"From:       " + account.Address.Hex(),
+		"Amount:     1 NEW",
+		"Nonce:      3",
+		"Gas limit:  21000",
+		"Gas price:  2 GWEI",
+		"Max fee:    42000000000000 WEI",
+		"Total:      1.000042 NEW",
+		"Transaction hash:",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q not in the output:\n%s", want, out)
+		}
+	}
+	if len(eth.sent) != 1 {
+		t.Fatalf("want 1 transaction sent, got %d", len(eth.sent))
+	}
+	tx := eth.sent[0]
+	if tx.Nonce() != 3 || tx.Gas() != 21000 || tx.GasPrice().Int64() != 2000000000 || tx.Value().String() != "1000000000000000000" {
+		t.Errorf("wrong transaction: nonce %d, gas %d, gas price %v, value %v", tx.Nonce(), tx.Gas(), tx.GasPrice(), tx.Value())
+	}
+	if from, err := types.Sender(types.NewEIP155Signer(big.NewInt(1007)), tx); err != nil || from != account.Address {
+		t.Errorf("wrong sender %s: %v", from.Hex(), err)
+	}
+	if !strings.Contains(out, tx.Hash().Hex()) {
+		t.Errorf("hash %s not in the output:\n%s", tx.Hash().Hex(), out)
+	}
+}
+
+func TestNewTransaction(t *testing.T) {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", &stubSendEth{}); err != nil {
+		t.Fatal(err)
+	}
+	node := httptest.NewServer(server)
+	defer node.Close()
+	client, err := ethclient.Dial(node.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	cli := newTestCLI()
+	to := common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481")
+	// the local nonce behind the chain moves to the latest nonce
+	for local, want := range map[uint64]uint64{1: 2, 5: 5} {
+		nonce := local
+		tx := cli.newTransaction(context.Background(), client, common.HexToAddress("0x8709Fe1cB55C6aB630456C887af578e7bE9F7490"), &nonce, to, big.NewInt(7))
+		if tx.Nonce() != want || nonce != want {
+			t.Errorf("(%d) want nonce %d, got %d %d", local, want, tx.Nonce(), nonce)
+		}
+		if *tx.To() != to || tx.Value().Int64() != 7 || tx.Gas() != 21000 || tx.GasPrice().Int64() != 2000000000 {
+			t.Errorf("wrong transaction: %v %v %d %v", tx.To(), tx.Value(), tx.Gas(), tx.GasPrice())
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// certReloader loads the TLS certificate again when its files change on disk,
+// so a rotated certificate is used without restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// modTimes returns the modification times of the certificate and the key files
+func (c *certReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// reload loads the files. The caller must hold c.mu or own c.
+func (c *certReloader) reload() error {
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+// GetCertificate returns the current certificate for tls.Config. If the new
+// files are invalid, e.g. the certificate is replaced but the key is not yet,
+// the last valid certificate is kept and the files are loaded again on the
+// next handshake.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	certMod, keyMod, err := c.modTimes()
+	if err == nil && (!certMod.Equal(c.certMod) || !keyMod.Equal(c.keyMod)) {
+		if err := c.reload(); err != nil {
+			log.Printf("reload TLS certificate error: %v", err)
+		} else {
+			log.Printf("TLS certificate %s reloaded", c.certFile)
+		}
+	}
+	return c.cert, nil
+}
+
+// newServer returns the faucet server of the handler with the timeouts and
+// the size limits of the config
+func newServer(handler http.Handler) (*http.Server, error) {
+	durations := make(map[string]time.Duration)
+	for _, key := range []string{"readHeaderTimeout", "readTimeout", "writeTimeout", "idleTimeout"} {
+		d := viper.GetDuration("faucet." + key)
+		if d < 0 {
+			return nil, fmt.Errorf("%s less than 0: %v", key, d)
+		}
+		durations[key] = d
+	}
+	maxHeaderBytes := viper.GetInt("faucet.maxHeaderBytes")
+	if maxHeaderBytes <= 0 {
+		return nil, fmt.Errorf("maxHeaderBytes less than 1: %d", maxHeaderBytes)
+	}
+	maxBodyBytes := viper.GetInt64("faucet.maxBodyBytes")
+	if maxBodyBytes <= 0 {
+		return nil, fmt.Errorf("maxBodyBytes less than 1: %d", maxBodyBytes)
+	}
+
+	server := &http.Server{
+		Handler:           http.MaxBytesHandler(handler, maxBodyBytes),
+		ReadHeaderTimeout: durations["readHeaderTimeout"],
+		ReadTimeout:       durations["readTimeout"],
+		// the event streams extend the write deadline for each event
+		WriteTimeout:   durations["writeTimeout"],
+		IdleTimeout:    durations["idleTimeout"],
+		MaxHeaderBytes: maxHeaderBytes,
+	}
+
+	certFile, keyFile := viper.GetString("faucet.tlsCert"), viper.GetString("faucet.tlsKey")
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("both tlsCert and tlsKey required for TLS")
+	}
+	if certFile != "" {
+		reloader, err := newCertReloader(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			NextProtos:     []string{"h2", "http/1.1"},
+			GetCertificate: reloader.GetCertificate,
+		}
+	}
+
+	return server, nil
+}
+
+// serve serves the faucet on `faucet.bind`:port, with TLS if configured,
+// and on the unix socket `faucet.socket` if set. It returns the first error
+// of the listeners.
+func (cli *CLI) serve(server *http.Server) error {
+	addr := net.JoinHostPort(viper.GetString("faucet.bind"), strconv.Itoa(cli.port))
+	tcpListener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	// Serve the TLS listener instead of ServeTLS, which would set up HTTP/2
+	// concurrently with Serve of the unix socket
+	if server.TLSConfig != nil {
+		fmt.Printf("Faucet serve started(https://%v)\n", tcpListener.Addr())
+		tcpListener = tls.NewListener(tcpListener, server.TLSConfig)
+	} else {
+		fmt.Printf("Faucet serve started(%v)\n", tcpListener.Addr())
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- server.Serve(tcpListener)
+	}()
+
+	// the unix socket is plain HTTP for the sidecar proxies terminating TLS
+	if socket := viper.GetString("faucet.socket"); socket != "" {
+		unixListener, err := listenUnix(socket)
+		if err != nil {
+			server.Close()
+			return err
+		}
+		fmt.Printf("Faucet serve started(%v)\n", unixListener.Addr())
+		go func() {
+			errc <- server.Serve(unixListener)
+		}()
+	}
+
+	return <-errc
+}
+
+// listenUnix listens on the unix socket, removing the socket file left by a
+// previous run. Any other file, or a socket still accepting connections, is
+// kept and returned as an error.
+func listenUnix(socket string) (net.Listener, error) {
+	fi, err := os.Lstat(socket)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, err
+	case fi.Mode()&os.ModeSocket == 0:
+		return nil, fmt.Errorf("%s exists and is not a socket", socket)
+	default:
+		if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use", socket)
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Exit codes of the faucet server after shutdown
+const (
+	exitShutdownOK      = 0
+	exitShutdownTimeout = 2 // a send may be interrupted, check the nonce on restart
+)
+
+// shutdown stops the servers from accepting requests, stops the ticket sender
+// after the send in progress, and waits for the in-flight requests and sends
+// at most timeout. The signer is closed once the sender is done. It returns
+// the exit code of the process.
+func (cli *CLI) shutdown(timeout time.Duration, servers ...*http.Server) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if cli.admin != nil {
+		servers = append(servers, cli.admin)
+	}
+
+	// wake the sender if paused
+	cli.mu.Lock()
+	close(cli.stop)
+	cli.pauseCond.Broadcast()
+	cli.mu.Unlock()
+
+	code := exitShutdownOK
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, server := range servers {
+		wg.Add(1)
+		go func(server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				log.Printf("Shutdown server error: %v", err)
+				mu.Lock()
+				code = exitShutdownTimeout
+				mu.Unlock()
+			}
+		}(server)
+	}
+
+	select {
+	case <-cli.senderDone:
+		// no send can use the signer anymore
+		cli.closeSigner()
+	case <-ctx.Done():
+		log.Printf("Shutdown timed out waiting for the ticket sender")
+		mu.Lock()
+		code = exitShutdownTimeout
+		mu.Unlock()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if code == exitShutdownOK {
+		queued := len(cli.tickets.list(ticketQueued))
+		log.Printf("Shutdown completed, %d queued tickets saved for the next start", queued)
+	}
+	return code
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+)
+
+func TestShutdownKeepsQueuedTickets(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	cli.tickets, err = newTicketStore(filepath.Join(t.TempDir(), "tickets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.queue = make(chan string, 1)
+	cli.stop = make(chan struct{})
+	cli.senderDone = make(chan struct{})
+	cli.pauseCond = sync.NewCond(&cli.mu)
+	cli.paused = true
+
+	now := time.Now()
+	queued := &ticket{ID: newTicketID(), Address: common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"), Status: ticketQueued, CreatedAt: now, UpdatedAt: now}
+	if err := cli.tickets.add(queued); err != nil {
+		t.Fatal(err)
+	}
+	cli.queue <- queued.ID
+	go cli.sendTickets()
+
+	// the sender is waiting for resume with the ticket
+	time.Sleep(10 * time.Millisecond)
+	if code := cli.shutdown(time.Second); code != exitShutdownOK {
+		t.Errorf("wrong exit code: want %d, got %d", exitShutdownOK, code)
+	}
+	if got, _ := cli.tickets.get(queued.ID); got.Status != ticketQueued {
+		t.Errorf("wrong ticket status after shutdown: %v", got.Status)
+	}
+}
+
+func TestShutdownClosesStreams(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	cli.tickets, err = newTicketStore(filepath.Join(t.TempDir(), "tickets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.events = newEventBus()
+	cli.stop = make(chan struct{})
+	cli.senderDone = make(chan struct{})
+	close(cli.senderDone)
+	cli.pauseCond = sync.NewCond(&cli.mu)
+	viper.Set("faucet.websocket", true)
+	defer viper.Set("faucet.websocket", nil)
+
+	now := time.Now()
+	queued := &ticket{ID: newTicketID(), Address: common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"), Status: ticketQueued, CreatedAt: now, UpdatedAt: now}
+	if err := cli.tickets.add(queued); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(cli.eventsHandler))
+	defer ts.Close()
+
+	// an SSE and a WebSocket subscriber of the queued ticket
+	resp, err := http.Get(ts.URL + "?ticket=" + queued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	sse := bufio.NewReader(resp.Body)
+	for _, want := range []string{eventValidated, eventQueued} {
+		if line, _ := sse.ReadString('\n'); line != "event: "+want+"\n" {
+			t.Fatalf("wrong SSE event line: want %q, got %q", want, line)
+		}
+		// data and the blank line
+		sse.ReadString('\n')
+		sse.ReadString('\n')
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"?ticket="+queued.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, want := range []string{eventValidated, eventQueued} {
+		var e requestEvent
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != want {
+			t.Fatalf("wrong WebSocket event: want %v, got %v", want, e.Type)
+		}
+	}
+
+	start := time.Now()
+	if code := cli.shutdown(2*time.Second, ts.Config); code != exitShutdownOK {
+		t.Errorf("wrong exit code: want %d, got %d", exitShutdownOK, code)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("shutdown waited for the streams: %v", d)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("wrong WebSocket close: %v", err)
+	}
+}
+
+// closingSigner records the Close of the signer
+type closingSigner struct {
+	signer
+	closed bool
+}
+
+func (s *closingSigner) Close() {
+	s.closed = true
+}
+
+func TestShutdownClosesSigner(t *testing.T) {
+	cli := newTestCLI()
+	var err error
+	cli.tickets, err = newTicketStore(filepath.Join(t.TempDir(), "tickets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.stop = make(chan struct{})
+	cli.senderDone = make(chan struct{})
+	close(cli.senderDone)
+	cli.pauseCond = sync.NewCond(&cli.mu)
+	s := &closingSigner{}
+	cli.signer = s
+
+	if code := cli.shutdown(time.Second); code != exitShutdownOK {
+		t.Errorf("wrong exit code: want %d, got %d", exitShutdownOK, code)
+	}
+	if !s.closed {
+		t.Error("signer not closed after shutdown")
+	}
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/spf13/viper"
+)
+
+// the values of `faucet.signer`
+const (
+	signerKeystore = "keystore"
+	signerHD       = "hd"
+	signerClef     = "clef"
+	signerPKCS11   = "pkcs11"
+	signerVault    = "vault"
+)
+
+// signer signs the transactions of the faucet accounts
+type signer interface {
+	SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// closeSigner releases the signer holding a session, the Vault token renewal
+// or the PKCS#11 login
+func (cli *CLI) closeSigner() {
+	if closer, ok := cli.signer.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
+
+// keystoreSigner signs with the accounts unlocked in the keystore, so the
+// keys are not decrypted with scrypt again for each payout
+type keystoreSigner struct {
+	ks *keystore.KeyStore
+}
+
+func (s keystoreSigner) SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.ks.SignTx(accounts.Account{Address: from}, tx, chainID)
+}
+
+// hdSigner signs with the keys of the HD accounts
+type hdSigner []*hdAccount
+
+func (s hdSigner) SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	for _, a := range s {
+		if a.address == from {
+			return types.SignTx(tx, types.NewEIP155Signer(chainID), a.key)
+		}
+	}
+	return nil, fmt.Errorf("%s not in the HD accounts", from.Hex())
+}
+
+// clefSigner sends the transactions to sign to a Clef compatible external
+// signer, so the keys are not in the faucet process
+type clefSigner struct {
+	ext *external.ExternalSigner
+}
+
+// newClefSigner connects to the signer at the HTTP URL or the IPC path
+func newClefSigner(endpoint string) (*clefSigner, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("clef.endpoint required")
+	}
+	ext, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("connect to the external signer %s: %v", endpoint, err)
+	}
+	return &clefSigner{ext: ext}, nil
+}
+
+// SignTx signs the transaction by account_signTransaction, and checks that
+// the signed transaction is the one requested from the account
+func (s *clefSigner) SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	signed, err := s.ext.SignTx(accounts.Account{Address: from}, tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignedTx(from, tx, signed, chainID); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// checkSignedTx checks that the transaction signed by a remote signer is
+// the requested one from the account
+func checkSignedTx(from common.Address, tx, signed *types.Transaction, chainID *big.Int) error {
+	if signed == nil {
+		return fmt.Errorf("no transaction signed by the external signer")
+	}
+	sender, err := types.Sender(types.NewEIP155Signer(chainID), signed)
+	if err != nil {
+		return err
+	}
+	if sender != from {
+		return fmt.Errorf("transaction signed by %s instead of %s", sender.Hex(), from.Hex())
+	}
+	if signed.Nonce() != tx.Nonce() || signed.To() == nil || *signed.To() != *tx.To() ||
+		signed.Value().Cmp(tx.Value()) != 0 || signed.Gas() != tx.Gas() || signed.GasPrice().Cmp(tx.GasPrice()) != 0 {
+		return fmt.Errorf("transaction changed by the external signer")
+	}
+	return nil
+}
+
+// signTxWith signs the transaction by sign, which returns the raw ECDSA
+// signature of the hash by the key pub, e.g. in a hardware token
+func signTxWith(tx *types.Transaction, chainID *big.Int, pub *ecdsa.PublicKey, sign func(hash []byte) (r, s *big.Int, err error)) (*types.Transaction, error) {
+	txSigner := types.NewEIP155Signer(chainID)
+	hash := txSigner.Hash(tx).Bytes()
+	r, s, err := sign(hash)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ethSignature(hash, r, s, pub)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(txSigner, sig)
+}
+
+// ethSignature normalizes the ECDSA signature of the hash to the Ethereum
+// form R || S || V, with S in the lower half of the order and the recovery
+// ID V of the key pub
+func ethSignature(hash []byte, r, s *big.Int, pub *ecdsa.PublicKey) ([]byte, error) {
+	n := crypto.S256().Params().N
+	if r.Sign() <= 0 || r.Cmp(n) >= 0 || s.Sign() <= 0 || s.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s = new(big.Int).Sub(n, s)
+	}
+
+	sig := make([]byte, crypto.SignatureLength)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:64])
+	want := crypto.FromECDSAPub(pub)
+	for v := byte(0); v < 2; v++ {
+		sig[64] = v
+		recovered, err := crypto.Ecrecover(hash, sig)
+		if err == nil && bytes.Equal(recovered, want) {
+			return sig, nil
+		}
+	}
+	return nil, fmt.Errorf("signature not by the key %s", crypto.PubkeyToAddress(*pub).Hex())
+}
+
+// unlockSender unlocks the account to send from by `faucet.signer`. For the
+// HD wallet it is one of the first `hd.accounts`, the account 0 by default.
+func (cli *CLI) unlockSender(fromAddress string) (common.Address, error) {
+	switch signer := viper.GetString("faucet.signer"); signer {
+	case signerKeystore:
+		if fromAddress == "" {
+			return common.Address{}, fmt.Errorf("Error: required flag(s) \"from\" not set")
+		}
+		account, err := cli.unlockAccount(fromAddress)
+		return account.Address, err
+	case signerHD:
+		hdAccounts, err := cli.unlockHDWallet(viper.GetInt("hd.accounts"))
+		if err != nil {
+			return common.Address{}, err
+		}
+		cli.hdAccounts = hdAccounts
+		cli.signer = hdSigner(hdAccounts)
+		if fromAddress == "" {
+			return hdAccounts[0].address, nil
+		}
+		for _, a := range hdAccounts {
+			if a.address == common.HexToAddress(fromAddress) {
+				return a.address, nil
+			}
+		}
+		return common.Address{}, fmt.Errorf("Error: %s not in the first %d accounts of the HD wallet", fromAddress, len(hdAccounts))
+	case signerClef:
+		if !common.IsHexAddress(fromAddress) {
+			return common.Address{}, fmt.Errorf("Error: required flag(s) \"from\" not set or invalid")
+		}
+		s, err := newClefSigner(viper.GetString("clef.endpoint"))
+		if err != nil {
+			return common.Address{}, fmt.Errorf("Error: %v", err)
+		}
+		cli.signer = s
+		return common.HexToAddress(fromAddress), nil
+	case signerPKCS11:
+		s, err := newPKCS11Signer(viper.GetString("pkcs11.module"), viper.GetString("pkcs11.token"), viper.GetString("pkcs11.label"))
+		if err != nil {
+			return common.Address{}, err
+		}
+		if fromAddress != "" && common.HexToAddress(fromAddress) != s.Address() {
+			s.Close()
+			return common.Address{}, fmt.Errorf("Error: %s is not the PKCS#11 key %s", fromAddress, s.Address().Hex())
+		}
+		cli.signer = s
+		return s.Address(), nil
+	case signerVault:
+		s, err := newVaultSigner(vaultConfigFromViper())
+		if err != nil {
+			return common.Address{}, fmt.Errorf("Error: Vault: %v", err)
+		}
+		if fromAddress != "" && common.HexToAddress(fromAddress) != s.Address() {
+			s.Close()
+			return common.Address{}, fmt.Errorf("Error: %s is not the Vault key %s", fromAddress, s.Address().Hex())
+		}
+		cli.signer = s
+		return s.Address(), nil
+	default:
+		return common.Address{}, fmt.Errorf("Error: unknown signer %q", signer)
+	}
+}
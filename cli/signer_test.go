@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// stubClef is the account API of Clef signing with the key
+type stubClef struct {
+	key     *ecdsa.PrivateKey
+	chainID *big.Int
+}
+
+func (s *stubClef) Version() string {
+	return "6.0.0"
+}
+
+// stubTxArgs is the legacy transaction of account_signTransaction, declared
+// here as signer/core/apitypes is not in the newchain fork used by the newton
+// build
+type stubTxArgs struct {
+	From     common.Address `json:"from"`
+	To       common.Address `json:"to"`
+	Gas      hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big   `json:"gasPrice"`
+	Value    *hexutil.Big   `json:"value"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+}
+
+func (s *stubClef) SignTransaction(args stubTxArgs) (map[string]interface{}, error) {
+	tx := types.NewTransaction(uint64(args.Nonce), args.To, args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), nil)
+	signed, err := types.SignTx(tx, types.NewEIP155Signer(s.chainID), s.key)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"raw": hexutil.Bytes(raw), "tx": signed}, nil
+}
+
+func newStubClef(t *testing.T, key *ecdsa.PrivateKey, chainID *big.Int) *httptest.Server {
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", &stubClef{key: key, chainID: chainID}); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClefSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(1007)
+	tx := types.NewTransaction(3, common.HexToAddress("0x8709Fe1cB55C6aB630456C887af578e7bE9F7490"), big.NewInt(1e18), 21000, big.NewInt(1e9), nil)
+
+	s, err := newClefSigner(newStubClef(t, key, chainID).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := s.SignTx(from, tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender, _ := types.Sender(types.NewEIP155Signer(chainID), signed); sender != from {
+		t.Errorf("sender %s, want %s", sender.Hex(), from.Hex())
+	}
+
+	// the signer signs with another account
+	other, _ := crypto.GenerateKey()
+	s, err = newClefSigner(newStubClef(t, other, chainID).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SignTx(from, tx, chainID); err == nil {
+		t.Error("transaction of another account accepted")
+	}
+
+	if _, err := newClefSigner(""); err == nil {
+		t.Error("empty endpoint accepted")
+	}
+}
+
+func TestSignTxWith(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(1007)
+	tx := types.NewTransaction(3, common.Address{}, big.NewInt(1), 21000, big.NewInt(1), nil)
+
+	// the raw ECDSA signatures of a token, with the high S half of the time
+	n := crypto.S256().Params().N
+	for i := 0; i < 8; i++ {
+		highS := i%2 == 1
+		signed, err := signTxWith(tx, chainID, &key.PublicKey, func(hash []byte) (*big.Int, *big.Int, error) {
+			r, s, err := ecdsa.Sign(rand.Reader, key, hash)
+			if err == nil && highS == (s.Cmp(new(big.Int).Rsh(n, 1)) <= 0) {
+				s.Sub(n, s)
+			}
+			return r, s, err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sender, err := types.Sender(types.NewEIP155Signer(chainID), signed); err != nil || sender != from {
+			t.Errorf("wrong sender %s (%v)", sender.Hex(), err)
+		}
+	}
+
+	// signed by another key
+	other, _ := crypto.GenerateKey()
+	_, err := signTxWith(tx, chainID, &key.PublicKey, func(hash []byte) (*big.Int, *big.Int, error) {
+		return ecdsa.Sign(rand.Reader, other, hash)
+	})
+	if err == nil {
+		t.Error("signature of another key accepted")
+	}
+}
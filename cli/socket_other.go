@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cli
+
+import "net"
+
+// listenPrivateUnix listens on the unix socket, which has no mode bits to
+// restrict on this platform
+func listenPrivateUnix(socket string) (net.Listener, error) {
+	return listenUnix(socket)
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package cli
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenPrivateUnix listens on the unix socket accessible by the owner only.
+// The socket is created under the umask 0077 rather than changed after, so no
+// other user can connect before the mode is set. The umask is of the process,
+// the files created meanwhile are only more private.
+func listenPrivateUnix(socket string) (net.Listener, error) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+
+	return listenUnix(socket)
+}
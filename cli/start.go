@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/spf13/cobra"
@@ -16,7 +16,7 @@ import (
 
 func (cli *CLI) buildStartCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "start [-port 8888] [--from address] [--amount 16888] [--unit NEW]",
+		Use:   "start [-port 8888] [--from address] [--amount 16888] [--unit NEW] [--cooldown 24h] [--budget amount]",
 		Short: "start " + cli.name + " server",
 		Args:  cobra.MinimumNArgs(0),
 		DisableFlagsInUseLine: true,
@@ -27,68 +27,105 @@ func (cli *CLI) buildStartCmd() *cobra.Command {
 			cli.port = port
 
 			amountStr := viper.GetString("faucet.amount")
-			unit := viper.GetString("faucet.unit")
-			d := stringInSlice(unit, DenominationList)
-			if !d {
+			unit, ok := normalizeUnit(viper.GetString("faucet.unit"))
+			if !ok {
 				fmt.Printf("Unit(%s) for amount error. %s.\n", unit, DenominationString)
 				fmt.Fprint(os.Stderr, cmd.UsageString())
 				return
 			}
 
-			amountWei, ok := getAmountWei(amountStr, unit)
-			if !ok {
-				fmt.Println("Get amount error:", amountStr)
+			amountWei, err := getAmountWei(amountStr, unit)
+			if err != nil {
+				fmt.Println("Get amount error:", err)
 				fmt.Fprint(os.Stderr, cmd.UsageString())
 				return
 			}
 			cli.amountWei = amountWei
+			cli.amount = amountStr
+			cli.unit = unit
 
-			fromAddress := viper.GetString("faucet.from")
-			if fromAddress == "" {
-				fmt.Println("Error: required flag(s) \"from\" not set")
+			cli.cooldown = viper.GetDuration("faucet.cooldown")
+			if cli.cooldown < 0 {
+				fmt.Println("Error: cooldown less than 0:", cli.cooldown)
 				fmt.Fprint(os.Stderr, cmd.UsageString())
 				return
 			}
-			cli.coinbase = fromAddress
 
-			walletPath := cli.walletPath
-			rpcURL := cli.rpcURL
+			budgetStr := viper.GetString("faucet.budget")
+			if budgetStr != "" {
+				budgetWei, err := getAmountWei(budgetStr, unit)
+				if err != nil {
+					fmt.Println("Get budget error:", err)
+					fmt.Fprint(os.Stderr, cmd.UsageString())
+					return
+				}
+				cli.budgetWei = budgetWei
+			}
+			cli.powDifficulty = viper.GetInt("faucet.powDifficulty")
+			if cli.powDifficulty < 0 || cli.powDifficulty > 64 {
+				fmt.Println("Error: powDifficulty should be in [0, 64]:", cli.powDifficulty)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+			cli.powSalt = newPoWSalt()
 
-			ks := keystore.NewKeyStore(walletPath,
-				keystore.LightScryptN, keystore.LightScryptP)
-			if len(ks.Accounts()) == 0 {
-				fmt.Println("Empty wallet, create account first.")
+			cli.catalogs, err = loadCatalogs()
+			if err != nil {
+				fmt.Println("Error: load message catalogs:", err)
 				return
 			}
 
-			account, err := ks.Find(accounts.Account{Address: common.HexToAddress(fromAddress)})
+			cli.tickets, err = newTicketStore(viper.GetString("faucet.ticketsFile"))
 			if err != nil {
-				fmt.Printf("Error: keystore find account(%s) error(%v)\n", fromAddress, err)
+				fmt.Println("Error: load tickets:", err)
+				return
+			}
+			queueSize := viper.GetInt("faucet.queueSize")
+			if queueSize <= 0 {
+				fmt.Println("Error: queueSize less than 1:", queueSize)
+				return
+			}
+			cli.queue = make(chan string, queueSize)
+			cli.ticketRetention = viper.GetDuration("faucet.ticketRetention")
+			confirmations := viper.GetInt("faucet.confirmations")
+			if confirmations < 1 {
+				fmt.Println("Error: confirmations less than 1:", confirmations)
 				return
 			}
+			cli.confirmations = uint64(confirmations)
+			cli.events = newEventBus()
+			cli.stop = make(chan struct{})
+			cli.senderDone = make(chan struct{})
 
-			walletPassword := viper.GetString("faucet.password")
-			isSetPwd := viper.IsSet("faucet.password")
-			var trials int
-			for trials = 0; trials < 3; trials++ {
-				prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", fromAddress, trials+1, 3)
-				if isSetPwd == false {
-					walletPassword, _ = getPassPhrase(prompt, false)
-				} else {
-					fmt.Println(prompt, "\nUse the `faucet.password` in the config file")
-				}
-				err = ks.Unlock(account, walletPassword)
-				if err == nil {
-					break
-				}
-				walletPassword = ""
+			cli.bans, err = newBanList(viper.GetString("faucet.bansFile"))
+			if err != nil {
+				fmt.Println("Error: load bans:", err)
+				return
 			}
-			cli.password = walletPassword
+			cli.pauseCond = sync.NewCond(&cli.mu)
 
-			if trials == 3 {
-				fmt.Printf("Error: Failed to unlock account %s (%v)\n", fromAddress, err)
+			cli.payouts, err = newPayoutLog(viper.GetString("faucet.payoutsFile"))
+			if err != nil {
+				fmt.Println("Error: load payouts:", err)
+				return
+			}
+
+			// the HD wallet pays out from all its accounts with the account 0 as the
+			// faucet address, the PKCS#11 token and Vault from the address of the key
+			fromAddress := viper.GetString("faucet.from")
+			switch viper.GetString("faucet.signer") {
+			case signerHD, signerPKCS11, signerVault:
+				fromAddress = ""
+			}
+			from, err := cli.unlockSender(fromAddress)
+			if err != nil {
+				fmt.Println(err)
 				return
 			}
+			fromAddress = from.Hex()
+			cli.coinbase = fromAddress
+
+			rpcURL := cli.rpcURL
 
 			client, err := ethclient.Dial(rpcURL)
 			if err != nil {
@@ -96,12 +133,18 @@ func (cli *CLI) buildStartCmd() *cobra.Command {
 				return
 			}
 			ctx := context.Background()
-			nonce, err := client.NonceAt(ctx, account.Address, nil)
+			nonce, err := client.NonceAt(ctx, common.HexToAddress(fromAddress), nil)
 			if err != nil {
 				fmt.Println("NonceAt error:", err)
 				return
 			}
 			cli.nonce = nonce
+			for _, a := range cli.hdAccounts[min(1, len(cli.hdAccounts)):] {
+				if *a.nonce, err = client.NonceAt(ctx, a.address, nil); err != nil {
+					fmt.Println("NonceAt error:", err)
+					return
+				}
+			}
 
 			// get ChainID
 			networkID, err := client.NetworkID(ctx)
@@ -121,12 +164,43 @@ func (cli *CLI) buildStartCmd() *cobra.Command {
 	cmd.Flags().StringP("unit", "u", "NEW", unitUsageString)
 	cmd.Flags().StringP("amount", "a", "16888", "Default faucet amount")
 	cmd.Flags().IntP("port", "p", 8888, "Default faucet server port `url`")
+	cmd.Flags().String("bind", "", "The `address` to listen on, empty for all interfaces")
+	cmd.Flags().Duration("cooldown", 0, "Minimum `duration` between two payouts to the same address, 0 for no limit")
+	cmd.Flags().String("budget", "", "Total `amount` the faucet may pay out in unit, empty for no limit")
+	cmd.Flags().Int("powDifficulty", 0, "Leading zero `bits` of the proof of work required for payouts, 0 for disabled")
 
 	viper.BindPFlag("faucet.from", cmd.Flags().Lookup("from"))
 	viper.BindPFlag("faucet.unit", cmd.Flags().Lookup("unit"))
 	viper.BindPFlag("faucet.amount", cmd.Flags().Lookup("amount"))
 
 	viper.BindPFlag("faucet.port", cmd.Flags().Lookup("port"))
+	viper.BindPFlag("faucet.bind", cmd.Flags().Lookup("bind"))
+	viper.BindPFlag("faucet.cooldown", cmd.Flags().Lookup("cooldown"))
+	viper.BindPFlag("faucet.budget", cmd.Flags().Lookup("budget"))
+	viper.BindPFlag("faucet.powDifficulty", cmd.Flags().Lookup("powDifficulty"))
 
 	return cmd
 }
+
+// unlockAccount finds the account in the wallet and unlocks it for signing
+func (cli *CLI) unlockAccount(fromAddress string) (accounts.Account, error) {
+	ks := newKeyStore(cli.walletPath)
+	if len(ks.Accounts()) == 0 {
+		return accounts.Account{}, fmt.Errorf("Empty wallet, create account first.")
+	}
+
+	account, err := ks.Find(accounts.Account{Address: common.HexToAddress(fromAddress)})
+	if err != nil {
+		return accounts.Account{}, fmt.Errorf("Error: keystore find account(%s) error(%v)", fromAddress, err)
+	}
+
+	err = unlockWithPassword("account "+fromAddress, func(password string) error {
+		return ks.Unlock(account, password)
+	})
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	cli.signer = keystoreSigner{ks: ks}
+
+	return account, nil
+}
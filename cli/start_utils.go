@@ -7,157 +7,337 @@ import (
 	"math/big"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	ethereum "github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/spf13/viper"
 )
 
 func (cli *CLI) startFaucet() {
-	port := cli.port
-	portStr := fmt.Sprintf("%v", port)
-	http.HandleFunc("/faucet", cli.faucetHandler)
-	http.HandleFunc("/balance", cli.getBalanceHandler)
-	addr := ":" + portStr
-	fmt.Printf("Faucet serve started(%v)\n", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	handler, err := cli.newRouter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	server, err := newServer(handler)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cli.startTicketWorker()
+
+	cli.startTime = time.Now()
+	if err := cli.startAdmin(); err != nil {
+		log.Fatal(err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cli.serve(server)
+	}()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errc:
+		log.Fatal(err)
+	case sig := <-signals:
+		log.Printf("Received %v, shutting down", sig)
+	}
+	go func() {
+		sig := <-signals
+		log.Printf("Received %v again, exit now", sig)
+		os.Exit(exitShutdownTimeout)
+	}()
+
+	os.Exit(cli.shutdown(viper.GetDuration("faucet.shutdownTimeout"), server))
 }
 
-func (cli *CLI) sendMoney(toAddressStr string) error {
-	// to address
-	if !common.IsHexAddress(toAddressStr) {
-		return fmt.Errorf("Not valid hex-encoded address")
+// newRouter returns the handler of all routes of the faucet with the
+// middleware chain `faucet.middleware`, and the policies `faucet.policies`
+// applied to the routes paying out money. The banned clients are rejected
+// after the policies if `eligibility` is not in them.
+func (cli *CLI) newRouter() (http.Handler, error) {
+	mws, err := cli.buildMiddleware(viper.GetStringSlice("faucet.middleware"))
+	if err != nil {
+		return nil, err
+	}
+	names := viper.GetStringSlice("faucet.policies")
+	policies, err := cli.buildMiddleware(names)
+	if err != nil {
+		return nil, err
+	}
+	// the bans of the admin API are enforced even if eligibility is not listed
+	if !slices.Contains(names, "eligibility") {
+		policies = append(policies, cli.eligibilityMiddleware)
+	}
+	payout := func(h http.HandlerFunc) http.Handler {
+		return chain(h, policies...)
 	}
-	toAddress := common.HexToAddress(toAddressStr)
 
-	// from address
-	wallet := keystore.NewKeyStore(cli.walletPath,
-		keystore.LightScryptN, keystore.LightScryptP)
-	if len(wallet.Accounts()) == 0 {
-		return fmt.Errorf("Empty wallet, create account first")
-	}
-	var account accounts.Account
-	for _, a := range wallet.Accounts() {
-		if a.Address == common.HexToAddress(cli.coinbase) {
-			account = a
-			break
+	mux := http.NewServeMux()
+	mux.Handle("/faucet", payout(cli.faucetHandler))
+	mux.HandleFunc("/balance", cli.getBalanceHandler)
+	mux.HandleFunc("/api/v1/info", cli.infoHandler)
+	mux.HandleFunc("GET /api/v1/balance", cli.balanceAPIHandler)
+	mux.Handle("POST /api/v1/faucet", payout(cli.faucetAPIHandler))
+	mux.HandleFunc("GET /api/v1/tx/{hash}", cli.txStatusHandler)
+	mux.Handle("POST /api/v1/requests", payout(cli.createRequestHandler))
+	mux.HandleFunc("GET /api/v1/requests/{id}", cli.getRequestHandler)
+	mux.HandleFunc("GET /api/v1/events", cli.eventsHandler)
+	if viper.GetBool("web.enabled") {
+		if err := cli.registerWebUI(mux); err != nil {
+			return nil, err
 		}
 	}
-	if account == (accounts.Account{}) {
-		return fmt.Errorf("Error: Can NOT get the keystore file of address %v", cli.coinbase)
-	}
-	wallet.Unlock(account, cli.password)
-	fmt.Println("account address:", account.Address.Hex())
 
-	// amount
-	amountWei := cli.amountWei
-	// networ id
-	networkID := cli.networkID
+	return chain(mux, mws...), nil
+}
+
+// sendMoney signs and broadcasts the payout to the address. If beforeSend is
+// not nil it is called with the signed transaction before the broadcast, and
+// the transaction is not broadcast if it returns error.
+func (cli *CLI) sendMoney(toAddressStr string, beforeSend func(tx *types.Transaction) error) (*types.Transaction, error) {
+	// to address
+	if !common.IsHexAddress(toAddressStr) {
+		return nil, newFaucetError("address.invalid")
+	}
+	toAddress := common.HexToAddress(toAddressStr)
 
-	// get gasLimit and gasPrice
 	client, err := ethclient.Dial(cli.rpcURL)
 	if err != nil {
 		log.Printf("client dial error: %v", err)
-		return err
+		return nil, err
 	}
 	defer client.Close()
 	ctx := context.Background()
+
+	from, nonce := cli.nextSender()
+	tx := cli.newTransaction(ctx, client, from, nonce, toAddress, cli.amountWei)
+	signTx, err := cli.signTx(from, tx)
+	if err != nil {
+		return nil, err
+	}
+	if beforeSend != nil {
+		if err := beforeSend(signTx); err != nil {
+			return nil, err
+		}
+	}
+
+	err = client.SendTransaction(ctx, signTx)
+	if err != nil {
+		return nil, fmt.Errorf("SendTransaction err (%v)", err)
+	}
+
+	*nonce++
+	return signTx, nil
+}
+
+// nextSender returns the faucet account of the next payout and its nonce,
+// rotating over the HD accounts if any. The caller must hold cli.sendMu.
+func (cli *CLI) nextSender() (common.Address, *uint64) {
+	if len(cli.hdAccounts) == 0 {
+		return common.HexToAddress(cli.coinbase), &cli.nonce
+	}
+	a := cli.hdAccounts[cli.hdNext%len(cli.hdAccounts)]
+	cli.hdNext++
+	return a.address, a.nonce
+}
+
+// senderAddresses returns the faucet accounts paying out, the account 0 of
+// the HD wallet first
+func (cli *CLI) senderAddresses() []common.Address {
+	if len(cli.hdAccounts) == 0 {
+		return []common.Address{common.HexToAddress(cli.coinbase)}
+	}
+	addresses := make([]common.Address, len(cli.hdAccounts))
+	for i, a := range cli.hdAccounts {
+		addresses[i] = a.address
+	}
+	return addresses
+}
+
+// senderBalances returns the balances of the faucet accounts and their total
+func (cli *CLI) senderBalances(ctx context.Context, client *ethclient.Client) ([]*big.Int, *big.Int, error) {
+	total := new(big.Int)
+	var balances []*big.Int
+	for _, address := range cli.senderAddresses() {
+		balance, err := client.BalanceAt(ctx, address, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		balances = append(balances, balance)
+		total.Add(total, balance)
+	}
+	return balances, total, nil
+}
+
+// senderNonce returns the nonce of the faucet account, or nil if the address
+// is not a faucet account. The caller must hold cli.sendMu.
+func (cli *CLI) senderNonce(from common.Address) *uint64 {
+	if len(cli.hdAccounts) == 0 {
+		if from == common.HexToAddress(cli.coinbase) {
+			return &cli.nonce
+		}
+		return nil
+	}
+	for _, a := range cli.hdAccounts {
+		if a.address == from {
+			return a.nonce
+		}
+	}
+	return nil
+}
+
+// newTransaction returns the unsigned transaction sending amountWei from the
+// account to the address with the next nonce, syncing the nonce to the chain
+func (cli *CLI) newTransaction(ctx context.Context, client *ethclient.Client, fromAddress common.Address, nonce *uint64, toAddress common.Address, amountWei *big.Int) *types.Transaction {
+	// get gasLimit and gasPrice
 	var gasPrice *big.Int
+	var err error
 	gasPrice, err = client.SuggestGasPrice(ctx)
 	if err != nil {
-		fmt.Println("SuggestGasPrice err:", err)
+		log.Printf("SuggestGasPrice error: %v, use gas price 1", err)
 		gasPrice = big.NewInt(1)
 	}
 	msg := ethereum.CallMsg{
-		From:     account.Address,
+		From:     fromAddress,
 		To:       &toAddress,
 		GasPrice: gasPrice,
 		Value:    amountWei,
 	}
 	gasLimit, err := client.EstimateGas(ctx, msg)
 	if err != nil {
-		fmt.Println("EstimateGas Error: ", err)
+		log.Printf("EstimateGas error: %v, use gas limit 21000", err)
 		gasLimit = 21000
 	}
 
 	// nonce
-	nonce, _ := client.NonceAt(ctx, account.Address, nil)
-	if cli.nonce < nonce {
-		cli.nonce = nonce
+	chainNonce, err := client.NonceAt(ctx, fromAddress, nil)
+	if err != nil {
+		log.Printf("NonceAt error: %v, use the local nonce %d", err, *nonce)
+	} else if *nonce < chainNonce {
+		*nonce = chainNonce
 	}
-	fmt.Println("nonce: ", cli.nonce)
 
-	tx := types.NewTransaction(cli.nonce, toAddress, amountWei, gasLimit, gasPrice, nil)
-	signTx, err := wallet.SignTx(account, tx, networkID)
+	return types.NewTransaction(*nonce, toAddress, amountWei, gasLimit, gasPrice, nil)
+}
 
-	err = client.SendTransaction(ctx, signTx)
+// signTx signs the transaction of the faucet account by the signer
+func (cli *CLI) signTx(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	if cli.signer == nil {
+		return nil, fmt.Errorf("Error: the account %v is not unlocked", from.Hex())
+	}
+	signTx, err := cli.signer.SignTx(from, tx, cli.networkID)
 	if err != nil {
-		return fmt.Errorf("SendTransaction err (%v)", err)
+		return nil, fmt.Errorf("SignTx err (%v)", err)
 	}
-
-	cli.nonce++
-	return nil
+	return signTx, nil
 }
 
-func (cli *CLI) getBalance(address string) *big.Int {
+// getBalance returns the latest balance of the address
+func (cli *CLI) getBalance(address string) (*big.Int, error) {
 	client, err := ethclient.Dial(cli.rpcURL)
 	if err != nil {
 		log.Printf("client dial error: %v", err)
-		return new(big.Int)
+		return nil, err
 	}
 	defer client.Close()
-	ctx := context.Background()
-	fmt.Println(address)
-	balance, err := client.BalanceAt(ctx, common.HexToAddress(address), nil)
+
+	balance, err := client.BalanceAt(context.Background(), common.HexToAddress(address), nil)
 	if err != nil {
-		fmt.Println("Balance error:", err)
-		os.Exit(1)
+		log.Printf("Balance error: %v", err)
+		return nil, err
 	}
-	fmt.Println("Balance: ", balance.String())
-	return balance
+	return balance, nil
 }
 
 func (cli *CLI) getBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	p := cli.printer(r)
 	r.ParseForm()
 	val, ok := r.Form["address"]
 	if !ok {
-		fmt.Fprintf(w, "Just give me a address!")
+		fmt.Fprint(w, p.Sprintf("address.missing"))
 		return
 	}
 	if len(val) != 1 {
-		fmt.Fprintf(w, "Just give me ONE address!")
+		fmt.Fprint(w, p.Sprintf("address.multiple"))
 		return
 	}
 	address := val[0]
 	// TODO: check address is valid.
 	log.Printf("faucet got address: %v", address)
-	amount := cli.getBalance(address)
+	amount, err := cli.getBalance(address)
+	if err != nil {
+		fmt.Fprint(w, p.Sprintf("faucet.error", p.Error(err)))
+		return
+	}
 
-	fmt.Fprintf(w, "balance: %v", getWeiAmountTextUnitByUnit(amount, ""))
+	fmt.Fprint(w, p.Sprintf("balance.result", formatAmount(amount, "")))
 }
 
 func (cli *CLI) faucetHandler(w http.ResponseWriter, r *http.Request) {
+	p := cli.printer(r)
 	r.ParseForm()
 	val, ok := r.Form["address"]
 	if !ok {
-		fmt.Fprintf(w, "Just give me a address!")
+		fmt.Fprint(w, p.Sprintf("address.missing"))
 		return
 	}
 	if len(val) != 1 {
-		fmt.Fprintf(w, "Just give me ONE address!")
+		fmt.Fprint(w, p.Sprintf("address.multiple"))
 		return
 	}
 	address := val[0]
-	// TODO: check address is valid.
 	log.Printf("faucet got address: %v", address)
-	err := cli.sendMoney(address)
+
+	_, err := cli.payout(address, r.Form.Get("pow"))
 	if err != nil {
-		fmt.Fprintf(w, "something is wrong: %v", err)
+		fmt.Fprint(w, p.Sprintf("faucet.error", p.Error(err)))
 		return
 	} else {
-		fmt.Fprintf(w, "Done! go check your money.") // send data to client side
+		fmt.Fprint(w, p.Sprintf("faucet.done")) // send data to client side
+	}
+}
+
+// payout checks the address and sends money to it synchronously. The
+// address can be in hex or NEW format. The ticket of the payout is
+// returned to follow the transaction.
+func (cli *CLI) payout(addressStr, pow string) (ticket, error) {
+	toAddress, err := parseAddress(addressStr, cli.networkID)
+	if err != nil {
+		return ticket{}, err
+	}
+	if err := cli.checkPoW(toAddress, pow); err != nil {
+		return ticket{}, err
+	}
+
+	cli.sendMu.Lock()
+	defer cli.sendMu.Unlock()
+
+	tx, err := cli.send(toAddress, nil)
+	if err != nil {
+		return ticket{}, err
+	}
+
+	now := time.Now()
+	hash, nonce := tx.Hash(), tx.Nonce()
+	t := &ticket{
+		ID:        newTicketID(),
+		Address:   toAddress,
+		Status:    ticketSent,
+		TxHash:    &hash,
+		Nonce:     &nonce,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
+	if err := cli.tickets.add(t); err != nil {
+		log.Printf("save ticket %s error: %v", t.ID, err)
+	}
+
+	return *t, nil
 }
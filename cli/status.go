@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// adminClient calls the admin API of a running faucet
+type adminClient struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+// newAdminClient connects to the unix socket if set, or to the listen address
+func newAdminClient(socket, listen, token string) (*adminClient, error) {
+	c := &adminClient{
+		client: &http.Client{Timeout: 30 * time.Second},
+		token:  token,
+	}
+
+	switch {
+	case socket != "":
+		c.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		}
+		c.baseURL = "http://unix"
+	case listen != "":
+		c.baseURL = listen
+		if !strings.HasPrefix(listen, "http://") && !strings.HasPrefix(listen, "https://") {
+			c.baseURL = "http://" + listen
+		}
+		c.baseURL = strings.TrimSuffix(c.baseURL, "/")
+	default:
+		return nil, fmt.Errorf("admin.socket or admin.listen required")
+	}
+
+	return c, nil
+}
+
+// adminClientFromCmd returns the client of the flags, or `admin.*` in the config
+// file for the flags not set
+func adminClientFromCmd(cmd *cobra.Command) (*adminClient, error) {
+	socket := flagOrConfig(cmd, "adminSocket", "admin.socket")
+	listen := flagOrConfig(cmd, "adminListen", "admin.listen")
+	if cmd.Flags().Changed("adminListen") && !cmd.Flags().Changed("adminSocket") {
+		socket = ""
+	}
+
+	return newAdminClient(socket, listen, flagOrConfig(cmd, "adminToken", "admin.token"))
+}
+
+// do calls the admin API and decodes the response body to out
+func (c *adminClient) do(method, path string, out interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s", apiErr.Error)
+		}
+		return fmt.Errorf("%s", resp.Status)
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+func addAdminClientFlags(cmd *cobra.Command) {
+	cmd.Flags().String("adminSocket", "", "The unix socket `path` of the admin API (default admin.socket in the config file)")
+	cmd.Flags().String("adminListen", "", "The `address` of the admin API (default admin.listen in the config file)")
+	cmd.Flags().String("adminToken", "", "The bearer `token` of the admin API (default admin.token in the config file)")
+	cmd.Flags().Bool("json", false, "Print the result as JSON")
+}
+
+func (cli *CLI) buildStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   "status [--adminSocket path | --adminListen address] [--json]",
+		Short:                 "Show the status of the running " + cli.name + " server",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := adminClientFromCmd(cmd)
+			if err != nil {
+				fmt.Println("Error:", err)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+
+			var status adminStatus
+			if err := client.do(http.MethodGet, "/admin/v1/status", &status); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+				printJSON(status)
+				return
+			}
+			printStatus(os.Stdout, status)
+		},
+	}
+	addAdminClientFlags(cmd)
+
+	return cmd
+}
+
+func (cli *CLI) buildPauseCmd() *cobra.Command {
+	return cli.buildPausedCmd("pause", "Pause the payouts of", true)
+}
+
+func (cli *CLI) buildResumeCmd() *cobra.Command {
+	return cli.buildPausedCmd("resume", "Resume the payouts of", false)
+}
+
+// buildPausedCmd builds the command to pause or resume the running server
+func (cli *CLI) buildPausedCmd(action, short string, paused bool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                   action + " [--adminSocket path | --adminListen address] [--json]",
+		Short:                 short + " the running " + cli.name + " server",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := adminClientFromCmd(cmd)
+			if err != nil {
+				fmt.Println("Error:", err)
+				fmt.Fprint(os.Stderr, cmd.UsageString())
+				return
+			}
+
+			var result map[string]bool
+			if err := client.do(http.MethodPost, "/admin/v1/"+action, &result); err != nil {
+				fmt.Println("Error:", err)
+				return
+			}
+
+			if jsonOutput, _ := cmd.Flags().GetBool("json"); jsonOutput {
+				printJSON(result)
+				return
+			}
+			if result["paused"] != paused {
+				fmt.Println("Error: unexpected paused state:", result["paused"])
+				return
+			}
+			if paused {
+				showSuccess("Faucet paused")
+			} else {
+				showSuccess("Faucet resumed")
+			}
+		},
+	}
+	addAdminClientFlags(cmd)
+
+	return cmd
+}
+
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+// printStatus prints the status as a table
+func printStatus(out io.Writer, status adminStatus) {
+	state := "running"
+	if status.Paused {
+		state = "paused"
+	}
+	balance := status.BalanceText
+	if balance == "" {
+		balance = "unknown"
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "State:\t%s\n", state)
+	fmt.Fprintf(w, "Version:\t%s\n", status.Version)
+	fmt.Fprintf(w, "Uptime:\t%s\n", status.Uptime)
+	fmt.Fprintf(w, "Address:\t%s\n", status.Address)
+	fmt.Fprintf(w, "Balance:\t%s\n", balance)
+	fmt.Fprintf(w, "Amount:\t%s %s\n", status.Amount, status.Unit)
+	fmt.Fprintf(w, "Cooldown:\t%s\n", status.Cooldown)
+	fmt.Fprintf(w, "Queue depth:\t%d\n", status.QueueDepth)
+	fmt.Fprintf(w, "Pending txs:\t%d\n", status.PendingTxs)
+	fmt.Fprintf(w, "Nonce:\t%d\n", status.Nonce)
+	if len(status.Accounts) > 1 {
+		fmt.Fprintf(w, "Accounts:\t\n")
+		for _, a := range status.Accounts {
+			accountBalance := a.BalanceText
+			if accountBalance == "" {
+				accountBalance = "unknown"
+			}
+			fmt.Fprintf(w, "  %s\tnonce %d, balance %s\n", a.Address, a.Nonce, accountBalance)
+		}
+	}
+	if len(status.LastErrors) == 0 {
+		fmt.Fprintf(w, "Last errors:\tnone\n")
+	} else {
+		fmt.Fprintf(w, "Last errors:\t\n")
+		for _, e := range status.LastErrors {
+			fmt.Fprintf(w, "  %s\t%s\n", e.Time.Local().Format(time.RFC3339), e.Error)
+		}
+	}
+	w.Flush()
+}
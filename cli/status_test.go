@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminClient(t *testing.T) {
+	server := httptest.NewServer(adminAuth("secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, adminStatus{Paused: true, QueueDepth: 3, Amount: "1", Unit: "NEW"})
+	})))
+	defer server.Close()
+
+	client, err := newAdminClient("", server.URL, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status adminStatus
+	if err := client.do(http.MethodGet, "/admin/v1/status", &status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Paused || status.QueueDepth != 3 {
+		t.Errorf("wrong status: %+v", status)
+	}
+
+	var out bytes.Buffer
+	printStatus(&out, status)
+	for _, want := range []string{"paused", "Queue depth:", "Last errors:  none"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("%q not in the table:\n%s", want, out.String())
+		}
+	}
+
+	client, _ = newAdminClient("", strings.TrimPrefix(server.URL, "http://"), "wrong")
+	if err := client.do(http.MethodGet, "/admin/v1/status", &status); err == nil || err.Error() != "unauthorized" {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	if _, err := newAdminClient("", "", ""); err == nil {
+		t.Errorf("client without address created")
+	}
+}
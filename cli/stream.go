@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+)
+
+const (
+	streamPingInterval = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	// The stream is read only and public as the status API
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// localizeEvent translates the error of the event to the language of the printer
+func localizeEvent(p printer, e requestEvent) requestEvent {
+	if e.ErrorCode != "" {
+		args := make([]interface{}, len(e.ErrorArgs))
+		for i, arg := range e.ErrorArgs {
+			args[i] = arg
+		}
+		e.Error = p.Sprintf(e.ErrorCode, args...)
+	}
+	return e
+}
+
+// eventsHandler streams the events of the ticket `ticket` or of the
+// transaction `tx` with Server-Sent Events, or with WebSocket if it is
+// enabled and the client asks for it.
+func (cli *CLI) eventsHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("ticket")
+	if id == "" {
+		if txStr := r.URL.Query().Get("tx"); txStr != "" {
+			hashBytes, err := hexutil.Decode(txStr)
+			if err != nil || len(hashBytes) != common.HashLength {
+				cli.writeJSONError(w, r, http.StatusBadRequest, newFaucetError("tx.invalidHash"))
+				return
+			}
+			if t, ok := cli.tickets.findByTxHash(common.BytesToHash(hashBytes)); ok {
+				id = t.ID
+			}
+		}
+	}
+
+	sub := cli.events.subscribe(id)
+	defer cli.events.unsubscribe(sub)
+
+	// get the ticket after subscribing, so no event is missed
+	t, ok := cli.tickets.get(id)
+	if !ok {
+		cli.writeJSONError(w, r, http.StatusNotFound, newFaucetError("ticket.notFound"))
+		return
+	}
+	initial := cli.initialEvents(t)
+	p := cli.printer(r)
+
+	if websocket.IsWebSocketUpgrade(r) && viper.GetBool("faucet.websocket") {
+		cli.streamWebSocket(w, r, p, sub, initial)
+		return
+	}
+	cli.streamSSE(w, r, p, sub, initial)
+}
+
+func (cli *CLI) streamSSE(w http.ResponseWriter, r *http.Request, p printer, sub *subscription, initial []requestEvent) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	rc := http.NewResponseController(w)
+	// the stream outlives the read timeout of the server
+	rc.SetReadDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(e requestEvent) error {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		data, err := json.Marshal(localizeEvent(p, e))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for _, e := range initial {
+		if err := write(e); err != nil || e.final() {
+			return
+		}
+	}
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	// Shutdown does not cancel the request context, so the stream stops on
+	// cli.stop for the server to finish
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cli.stop:
+			return
+		case e := <-sub.C:
+			if err := write(e); err != nil || e.final() {
+				return
+			}
+		case <-ping.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (cli *CLI) streamWebSocket(w http.ResponseWriter, r *http.Request, p printer, sub *subscription, initial []requestEvent) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("websocket upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// read until the client closes the connection
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(e requestEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		return conn.WriteJSON(localizeEvent(p, e))
+	}
+	finish := func() {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	}
+
+	for _, e := range initial {
+		if err := write(e); err != nil {
+			return
+		}
+		if e.final() {
+			finish()
+			return
+		}
+	}
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-cli.stop:
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+			return
+		case e := <-sub.C:
+			if err := write(e); err != nil {
+				return
+			}
+			if e.final() {
+				finish()
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,537 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	ticketQueued  = "queued"
+	ticketSending = "sending" // signed and saved, maybe not broadcast yet
+	ticketSent    = "sent"
+	ticketMined   = "mined"
+	ticketFailed  = "failed"
+)
+
+const receiptInterval = 5 * time.Second
+
+// ticket is an asynchronous faucet request
+type ticket struct {
+	ID          string         `json:"id"`
+	Address     common.Address `json:"address"`
+	Status      string         `json:"status"` // queued, sending, sent, mined or failed
+	TxHash      *common.Hash   `json:"txHash,omitempty"`
+	Nonce       *uint64        `json:"nonce,omitempty"`
+	RawTx       hexutil.Bytes  `json:"rawTx,omitempty"` // the signed transaction while sending
+	BlockNumber *uint64        `json:"blockNumber,omitempty"`
+	// Confirmations is the number of blocks since the transaction is mined, including its block
+	Confirmations uint64    `json:"confirmations,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	ErrorCode     string    `json:"errorCode,omitempty"` // message ID of the error
+	ErrorArgs     []string  `json:"errorArgs,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+func newTicketID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// fail marks the ticket failed with the error
+func (t *ticket) fail(err error) {
+	t.Status = ticketFailed
+	t.RawTx = nil
+	t.Error = err.Error()
+	t.ErrorCode, t.ErrorArgs = errorCode(err)
+}
+
+// ticketStore keeps the tickets in memory and persists them to a JSON file
+type ticketStore struct {
+	path    string
+	mu      sync.Mutex
+	tickets map[string]*ticket
+}
+
+// newTicketStore loads the tickets from path if it exists
+func newTicketStore(path string) (*ticketStore, error) {
+	s := &ticketStore{
+		path:    path,
+		tickets: make(map[string]*ticket),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tickets []*ticket
+	if err := json.Unmarshal(data, &tickets); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, t := range tickets {
+		s.tickets[t.ID] = t
+	}
+
+	return s, nil
+}
+
+// save writes all tickets to the file. The caller must hold s.mu.
+func (s *ticketStore) save() error {
+	tickets := make([]*ticket, 0, len(s.tickets))
+	for _, t := range s.tickets {
+		tickets = append(tickets, t)
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].CreatedAt.Before(tickets[j].CreatedAt) })
+
+	return writeJSONFile(s.path, tickets)
+}
+
+func (s *ticketStore) add(t *ticket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tickets[t.ID] = t
+	return s.save()
+}
+
+// get returns a copy of the ticket
+func (s *ticketStore) get(id string) (ticket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tickets[id]
+	if !ok {
+		return ticket{}, false
+	}
+	return *t, true
+}
+
+// update applies fn to the ticket and persists it
+func (s *ticketStore) update(id string, fn func(t *ticket)) (ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tickets[id]
+	if !ok {
+		return ticket{}, fmt.Errorf("ticket %s not found", id)
+	}
+	fn(t)
+	t.UpdatedAt = time.Now()
+
+	return *t, s.save()
+}
+
+// findByTxHash returns a copy of the ticket of the transaction
+func (s *ticketStore) findByTxHash(hash common.Hash) (ticket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tickets {
+		if t.TxHash != nil && *t.TxHash == hash {
+			return *t, true
+		}
+	}
+	return ticket{}, false
+}
+
+// list returns copies of the tickets with the status ordered by creation time
+func (s *ticketStore) list(status string) []ticket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tickets []ticket
+	for _, t := range s.tickets {
+		if t.Status == status {
+			tickets = append(tickets, *t)
+		}
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].CreatedAt.Before(tickets[j].CreatedAt) })
+
+	return tickets
+}
+
+// prune removes the mined and failed tickets not updated for the retention
+func (s *ticketStore) prune(retention time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned bool
+	for id, t := range s.tickets {
+		if (t.Status == ticketMined || t.Status == ticketFailed) && time.Since(t.UpdatedAt) > retention {
+			delete(s.tickets, id)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return s.save()
+}
+
+// enqueue validates the request and queues a ticket for it
+func (cli *CLI) enqueue(addressStr, pow string) (ticket, error) {
+	toAddress, err := parseAddress(addressStr, cli.networkID)
+	if err != nil {
+		return ticket{}, err
+	}
+	if err := cli.checkPoW(toAddress, pow); err != nil {
+		return ticket{}, err
+	}
+	if err := cli.checkPayout(toAddress); err != nil {
+		return ticket{}, err
+	}
+
+	now := time.Now()
+	t := &ticket{
+		ID:        newTicketID(),
+		Address:   toAddress,
+		Status:    ticketQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := cli.tickets.add(t); err != nil {
+		log.Printf("save ticket %s error: %v", t.ID, err)
+	}
+	select {
+	case cli.queue <- t.ID:
+	default:
+		err := newFaucetError("queue.full")
+		cli.failTicket(t.ID, err)
+		return ticket{}, err
+	}
+
+	// no client can subscribe to the ticket before it is returned, the
+	// streams start with the validated and the current state instead
+	return *t, nil
+}
+
+// failTicket marks the ticket failed and publishes the event
+func (cli *CLI) failTicket(id string, err error) {
+	t, saveErr := cli.tickets.update(id, func(t *ticket) { t.fail(err) })
+	if saveErr != nil {
+		log.Printf("save ticket %s error: %v", id, saveErr)
+	}
+	cli.events.publish(cli.ticketEvent(t))
+}
+
+// send sends money to the address if it is allowed now, and records the
+// payout. beforeSend is called with the signed transaction as sendMoney.
+// The caller must hold cli.sendMu.
+func (cli *CLI) send(toAddress common.Address, beforeSend func(tx *types.Transaction) error) (*types.Transaction, error) {
+	if err := cli.checkPayout(toAddress); err != nil {
+		return nil, err
+	}
+	tx, err := cli.sendMoney(toAddress.Hex(), beforeSend)
+	if err != nil {
+		cli.recordError(fmt.Errorf("send to %s: %v", toAddress.Hex(), err))
+		return nil, err
+	}
+	cli.recordPayout(toAddress)
+
+	return tx, nil
+}
+
+// startTicketWorker reconciles the sending tickets and requeues the queued
+// tickets of the last run, and starts the sender and the receipt watcher of
+// the tickets.
+func (cli *CLI) startTicketWorker() {
+	cli.reconcileTickets()
+	for _, t := range cli.tickets.list(ticketQueued) {
+		select {
+		case cli.queue <- t.ID:
+		default:
+			cli.failTicket(t.ID, newFaucetError("queue.full"))
+		}
+	}
+
+	go cli.sendTickets()
+	go cli.watchTickets()
+}
+
+// stopped reports whether the faucet is shutting down
+func (cli *CLI) stopped() bool {
+	select {
+	case <-cli.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// sendTickets sends money for the queued tickets one by one until shutdown.
+// The tickets not sent stay queued in the store for the next run.
+func (cli *CLI) sendTickets() {
+	defer close(cli.senderDone)
+
+	for {
+		var id string
+		select {
+		case <-cli.stop:
+			return
+		case id = <-cli.queue:
+		}
+
+		// keep the tickets queued while paused
+		cli.mu.Lock()
+		for cli.paused && !cli.stopped() {
+			cli.pauseCond.Wait()
+		}
+		cli.mu.Unlock()
+		if cli.stopped() {
+			return
+		}
+
+		t, ok := cli.tickets.get(id)
+		if !ok || t.Status != ticketQueued {
+			continue
+		}
+
+		cli.sendTicket(id, t.Address)
+	}
+}
+
+// sendTicket pays the ticket out. The signed transaction is saved before the
+// broadcast, so the ticket is reconciled with the chain instead of paid again
+// after a crash. The ticket leaves the sending state before cli.sendMu is
+// released, so reconcileSending never sees the ticket in flight.
+func (cli *CLI) sendTicket(id string, toAddress common.Address) {
+	cli.sendMu.Lock()
+	defer cli.sendMu.Unlock()
+
+	tx, err := cli.send(toAddress, func(tx *types.Transaction) error {
+		return cli.saveSending(id, tx)
+	})
+	if err != nil {
+		log.Printf("ticket %s failed: %v", id, err)
+		cli.failTicket(id, err)
+		return
+	}
+	log.Printf("ticket %s sent: %s", id, tx.Hash().Hex())
+	cli.markSent(id)
+}
+
+// saveSending saves the signed transaction of the ticket before the broadcast
+func (cli *CLI) saveSending(id string, tx *types.Transaction) error {
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return err
+	}
+	hash, nonce := tx.Hash(), tx.Nonce()
+	_, err = cli.tickets.update(id, func(t *ticket) {
+		t.Status = ticketSending
+		t.TxHash = &hash
+		t.Nonce = &nonce
+		t.RawTx = raw
+	})
+	if err != nil {
+		return fmt.Errorf("save ticket %s: %v", id, err)
+	}
+	return nil
+}
+
+// markSent marks the ticket broadcast and publishes the event
+func (cli *CLI) markSent(id string) {
+	t, err := cli.tickets.update(id, func(t *ticket) {
+		t.Status = ticketSent
+		t.RawTx = nil
+	})
+	if err != nil {
+		log.Printf("save ticket %s error: %v", id, err)
+	}
+	cli.events.publish(cli.ticketEvent(t))
+}
+
+// reconcileTickets reconciles the sending tickets of the last run
+func (cli *CLI) reconcileTickets() {
+	if len(cli.tickets.list(ticketSending)) == 0 {
+		return
+	}
+	client, err := ethclient.Dial(cli.rpcURL)
+	if err != nil {
+		log.Printf("client dial error: %v", err)
+		return
+	}
+	defer client.Close()
+
+	cli.sendMu.Lock()
+	defer cli.sendMu.Unlock()
+	cli.reconcileSending(client)
+}
+
+// reconcileSending checks the sending tickets of the last run against the
+// chain: the known transactions are sent, the others are broadcast again as
+// signed if their nonce is not used yet, or failed. The address is never paid
+// twice. The tickets are kept sending if the node is not reachable. The caller
+// must hold cli.sendMu: sendTicket moves its ticket out of sending before
+// releasing it, so no ticket of this run is in flight.
+func (cli *CLI) reconcileSending(client *ethclient.Client) {
+	ctx := context.Background()
+	for _, t := range cli.tickets.list(ticketSending) {
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(t.RawTx, tx); err != nil {
+			log.Printf("ticket %s: invalid signed transaction: %v", t.ID, err)
+			cli.failTicket(t.ID, newFaucetError("tx.notBroadcast"))
+			continue
+		}
+		from, err := types.Sender(types.NewEIP155Signer(cli.networkID), tx)
+		if err != nil {
+			log.Printf("ticket %s: invalid signed transaction: %v", t.ID, err)
+			cli.failTicket(t.ID, newFaucetError("tx.notBroadcast"))
+			continue
+		}
+
+		_, _, err = client.TransactionByHash(ctx, tx.Hash())
+		if err == nil {
+			log.Printf("ticket %s reconciled: %s was broadcast", t.ID, tx.Hash().Hex())
+			cli.useNonce(from, tx.Nonce())
+			cli.markSent(t.ID)
+			continue
+		}
+		if err != ethereum.NotFound {
+			log.Printf("TransactionByHash error: %v", err)
+			return
+		}
+
+		chainNonce, err := client.NonceAt(ctx, from, nil)
+		if err != nil {
+			log.Printf("NonceAt error: %v", err)
+			return
+		}
+		if chainNonce > tx.Nonce() {
+			log.Printf("ticket %s failed: nonce %d of %s used by another transaction", t.ID, tx.Nonce(), tx.Hash().Hex())
+			cli.failTicket(t.ID, newFaucetError("tx.notBroadcast"))
+			continue
+		}
+		if err := client.SendTransaction(ctx, tx); err != nil {
+			log.Printf("ticket %s failed: %v", t.ID, err)
+			cli.failTicket(t.ID, fmt.Errorf("SendTransaction err (%v)", err))
+			continue
+		}
+		log.Printf("ticket %s reconciled: %s broadcast again", t.ID, tx.Hash().Hex())
+		cli.useNonce(from, tx.Nonce())
+		cli.markSent(t.ID)
+	}
+}
+
+// useNonce moves the nonce of the faucet account after the nonce of a sent
+// transaction. The caller must hold cli.sendMu.
+func (cli *CLI) useNonce(from common.Address, nonce uint64) {
+	if n := cli.senderNonce(from); n != nil && *n <= nonce {
+		*n = nonce + 1
+	}
+}
+
+// watchTickets checks the receipts and the confirmations of the sent
+// tickets periodically
+func (cli *CLI) watchTickets() {
+	ticker := time.NewTicker(receiptInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := cli.tickets.prune(cli.ticketRetention); err != nil {
+			log.Printf("prune tickets error: %v", err)
+		}
+
+		// the tickets left sending if the node was not reachable at start
+		cli.reconcileTickets()
+
+		var watching []ticket
+		watching = append(watching, cli.tickets.list(ticketSent)...)
+		for _, t := range cli.tickets.list(ticketMined) {
+			if t.Confirmations < cli.confirmations {
+				watching = append(watching, t)
+			}
+		}
+		if len(watching) == 0 {
+			continue
+		}
+
+		client, err := ethclient.Dial(cli.rpcURL)
+		if err != nil {
+			log.Printf("client dial error: %v", err)
+			continue
+		}
+		cli.checkTickets(client, watching)
+		client.Close()
+	}
+}
+
+func (cli *CLI) checkTickets(client *ethclient.Client, watching []ticket) {
+	ctx := context.Background()
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		log.Printf("BlockNumber error: %v", err)
+		return
+	}
+
+	for _, t := range watching {
+		blockNumber := t.BlockNumber
+		var failed bool
+		if t.Status == ticketSent {
+			receipt, err := client.TransactionReceipt(ctx, *t.TxHash)
+			if err == ethereum.NotFound {
+				continue
+			}
+			if err != nil {
+				log.Printf("TransactionReceipt error: %v", err)
+				continue
+			}
+			number := receipt.BlockNumber.Uint64()
+			blockNumber = &number
+			failed = receipt.Status == types.ReceiptStatusFailed
+		}
+
+		var confirmations uint64
+		if head >= *blockNumber {
+			confirmations = head - *blockNumber + 1
+		}
+		if t.Status == ticketMined && confirmations == t.Confirmations {
+			continue
+		}
+
+		updated, err := cli.tickets.update(t.ID, func(t *ticket) {
+			t.BlockNumber = blockNumber
+			t.Confirmations = confirmations
+			if failed {
+				t.fail(newFaucetError("tx.failed"))
+			} else {
+				t.Status = ticketMined
+			}
+		})
+		if err != nil {
+			log.Printf("save ticket %s error: %v", t.ID, err)
+		}
+		if failed {
+			cli.recordError(fmt.Errorf("transaction %s failed", t.TxHash.Hex()))
+		}
+
+		e := cli.ticketEvent(updated)
+		if t.Status == ticketSent && e.Type != eventIncluded && e.Type != eventFailed {
+			included := e
+			included.Type = eventIncluded
+			cli.events.publish(included)
+		}
+		cli.events.publish(e)
+	}
+}
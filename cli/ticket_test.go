@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestTicketStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tickets.json")
+	store, err := newTicketStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	queued := &ticket{ID: newTicketID(), Address: common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"), Status: ticketQueued, CreatedAt: now, UpdatedAt: now}
+	mined := &ticket{ID: newTicketID(), Status: ticketMined, CreatedAt: old, UpdatedAt: old}
+	for _, tk := range []*ticket{queued, mined} {
+		if err := store.add(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.update(queued.ID, func(tk *ticket) { tk.fail(newFaucetError("pow.invalid")) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// reload from file
+	store, err = newTicketStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := store.get(queued.ID)
+	if !ok || got.Status != ticketFailed || got.ErrorCode != "pow.invalid" || got.Address != queued.Address {
+		t.Errorf("wrong ticket after reload: %+v", got)
+	}
+
+	if err := store.prune(24 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.get(mined.ID); ok {
+		t.Errorf("ticket %s not pruned", mined.ID)
+	}
+	if _, ok := store.get(queued.ID); !ok {
+		t.Errorf("ticket %s pruned", queued.ID)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp"))
+	if len(matches) != 0 {
+		t.Errorf("temp files left: %v", matches)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Error(err)
+	}
+}
+
+// stubEth is the eth API of a node with the known transactions
+type stubEth struct {
+	mu    sync.Mutex
+	known map[common.Hash]*types.Transaction
+	nonce uint64 // the nonce of all accounts
+	sent  int
+
+	attempts int           // the calls of eth_sendRawTransaction
+	hold     chan struct{} // blocks the broadcasts until closed if not nil
+	reject   error         // the error of the broadcasts if not nil
+}
+
+func (s *stubEth) GetTransactionByHash(hash common.Hash) *types.Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.known[hash]
+}
+
+func (s *stubEth) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return hexutil.Uint64(s.nonce)
+}
+
+func (s *stubEth) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, tx); err != nil {
+		return common.Hash{}, err
+	}
+	s.mu.Lock()
+	s.attempts++
+	hold, reject := s.hold, s.reject
+	s.mu.Unlock()
+	if hold != nil {
+		<-hold
+	}
+	if reject != nil {
+		return common.Hash{}, reject
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.known[tx.Hash()] = tx
+	s.sent++
+	return tx.Hash(), nil
+}
+
+func TestReconcileSending(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	cli := newTestCLI()
+	cli.networkID = big.NewInt(1007)
+	cli.coinbase = crypto.PubkeyToAddress(key.PublicKey).Hex()
+	cli.events = newEventBus()
+	var err error
+	cli.tickets, err = newTicketStore(filepath.Join(t.TempDir(), "tickets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eth := &stubEth{known: make(map[common.Hash]*types.Transaction), nonce: 5}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// the tickets signed with the nonces 4 (used by another tx), 5 (broadcast) and 6 (not broadcast)
+	var tickets []*ticket
+	for nonce := uint64(4); nonce <= 6; nonce++ {
+		tx := types.NewTransaction(nonce, common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"), big.NewInt(1), 21000, big.NewInt(1), nil)
+		signed, err := types.SignTx(tx, types.NewEIP155Signer(cli.networkID), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		tk := &ticket{ID: newTicketID(), Address: *tx.To(), Status: ticketQueued, CreatedAt: now, UpdatedAt: now}
+		if err := cli.tickets.add(tk); err != nil {
+			t.Fatal(err)
+		}
+		if err := cli.saveSending(tk.ID, signed); err != nil {
+			t.Fatal(err)
+		}
+		if nonce == 5 {
+			eth.known[signed.Hash()] = signed
+		}
+		tickets = append(tickets, tk)
+	}
+
+	client, err := ethclient.Dial(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	cli.reconcileSending(client)
+
+	for i, want := range []string{ticketFailed, ticketSent, ticketSent} {
+		if got, _ := cli.tickets.get(tickets[i].ID); got.Status != want || got.RawTx != nil {
+			t.Errorf("ticket %d: want %s, got %s", i, want, got.Status)
+		}
+	}
+	if eth.sent != 1 {
+		t.Errorf("want 1 transaction broadcast again, got %d", eth.sent)
+	}
+	if cli.nonce != 7 {
+		t.Errorf("wrong nonce after reconcile: %d", cli.nonce)
+	}
+}
+
+func TestReconcileWhileSending(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	eth := &stubEth{known: make(map[common.Hash]*types.Transaction), hold: make(chan struct{}), reject: errors.New("rejected")}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cli := newTestCLI()
+	cli.rpcURL = ts.URL
+	cli.networkID = big.NewInt(1007)
+	cli.amountWei = big.NewInt(1)
+	cli.hdAccounts = []*hdAccount{{address: crypto.PubkeyToAddress(key.PublicKey), key: key, nonce: &cli.nonce}}
+	cli.signer = hdSigner(cli.hdAccounts)
+	cli.events = newEventBus()
+	cli.pauseCond = sync.NewCond(&cli.mu)
+	cli.queue = make(chan string, 1)
+	cli.stop = make(chan struct{})
+	cli.senderDone = make(chan struct{})
+	dir := t.TempDir()
+	var err error
+	if cli.tickets, err = newTicketStore(filepath.Join(dir, "tickets.json")); err != nil {
+		t.Fatal(err)
+	}
+	if cli.bans, err = newBanList(filepath.Join(dir, "bans.json")); err != nil {
+		t.Fatal(err)
+	}
+	if cli.payouts, err = newPayoutLog(filepath.Join(dir, "payouts.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tk := &ticket{ID: newTicketID(), Address: common.HexToAddress("0xDB2C9C06E186D58EFe19f213b3d5FaF8B8c99481"), Status: ticketQueued, CreatedAt: now, UpdatedAt: now}
+	if err := cli.tickets.add(tk); err != nil {
+		t.Fatal(err)
+	}
+	cli.queue <- tk.ID
+	go cli.sendTickets()
+	defer func() {
+		close(cli.stop)
+		<-cli.senderDone
+	}()
+
+	// the broadcast is blocked with the ticket sending
+	for deadline := time.Now().Add(3 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		eth.mu.Lock()
+		attempts := eth.attempts
+		eth.mu.Unlock()
+		if attempts == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ticket not sent")
+		}
+	}
+	reconciled := make(chan struct{})
+	go func() {
+		cli.reconcileTickets()
+		close(reconciled)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(eth.hold)
+
+	select {
+	case <-reconciled:
+	case <-time.After(3 * time.Second):
+		t.Fatal("reconcile not finished")
+	}
+	if got, _ := cli.tickets.get(tk.ID); got.Status != ticketFailed {
+		t.Errorf("want ticket failed, got %s", got.Status)
+	}
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+	if eth.attempts != 1 {
+		t.Errorf("failed transaction broadcast again: %d broadcasts", eth.attempts)
+	}
+}
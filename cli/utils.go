@@ -1,17 +1,19 @@
 package cli
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"math/big"
-	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 
-	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func showSuccess(msg string, args ...interface{}) {
@@ -45,179 +47,130 @@ func getPassPhrase(promptStr string, confirmation bool) (string, error) {
 	return password, nil
 }
 
-func stringInSlice(str string, list []string) bool {
-	for _, v := range list {
-		if v == str {
-			return true
-		}
-	}
-	return false
-}
+// DenominationList is the available units, from the smallest
+var DenominationList = []string{"WEI", "KWEI", "MWEI", "GWEI", "SZABO", "FINNEY", "NEW"}
 
 // DenominationString is for denomination string
-// const DenominationString = "Available unit: Wei, Ada, Babbage, Shannon, Szabo, Finney, Ether, Einstein, Douglas, Gwei"
-const DenominationString = "Available unit: NEW, WEI"
-
-// DenominationList is array for denomination string
-// var DenominationList = []string{"Wei", "Ada", "Babbage", "Shannon", "Szabo", "Finney", "Ether", "Einstein", "Douglas", "Gwei"}
-var DenominationList = []string{"NEW", "WEI"}
-
-func getDenominationByUnit0(unit string) *big.Float {
-	bf := new(big.Float)
-	switch unit {
-	case "Ether":
-		bf.SetFloat64(params.Ether)
-	case "Wei":
-		bf.SetFloat64(params.Wei)
-	default:
-		bf.SetFloat64(params.Wei)
-	}
-
-	return bf
+var DenominationString = "Available unit: " + strings.Join(DenominationList, ", ")
+
+// unitDecimals is the number of the decimals of each unit in wei
+var unitDecimals = map[string]int{
+	"WEI":    0,
+	"KWEI":   3,
+	"MWEI":   6,
+	"GWEI":   9,
+	"SZABO":  12,
+	"FINNEY": 15,
+	"NEW":    18,
 }
 
-func getDenominationByUnit(unit string) *big.Float {
-	bf := new(big.Float)
-	switch unit {
-	case "NEW":
-		bf.SetFloat64(params.Ether)
-	case "WEI":
-		bf.SetFloat64(params.Wei)
-	default:
-		bf.SetFloat64(params.Wei)
-	}
+var amountPattern = regexp.MustCompile(`^([0-9]*)(?:\.([0-9]*))?$`)
 
-	return bf
+// normalizeUnit returns the unit in upper case, and whether it is available
+func normalizeUnit(unit string) (string, bool) {
+	unit = strings.ToUpper(strings.TrimSpace(unit))
+	_, ok := unitDecimals[unit]
+	return unit, ok
 }
 
-func getAmountWei(amountStr, unit string) (*big.Int, bool) {
-
-	amountFloat, ok := new(big.Float).SetString(amountStr)
+// getAmountWei parses the decimal amount in the unit to wei exactly. The
+// amount can not have more fractional digits than the decimals of the unit.
+func getAmountWei(amountStr, unit string) (*big.Int, error) {
+	unit, ok := normalizeUnit(unit)
 	if !ok {
-		return nil, ok
+		return nil, fmt.Errorf("unit %s not supported. %s", unit, DenominationString)
 	}
-	amountFloat.Mul(amountFloat, getDenominationByUnit(unit))
-	amount := new(big.Int)
-	amountFloat.Int(amount)
+	decimals := unitDecimals[unit]
 
-	return amount, ok
-}
-
-func getAmountTextByUnit(amount *big.Float, unit string) string {
-	amountF := amount.Quo(amount, getDenominationByUnit(unit))
-
-	precF := getDenominationByUnit(unit).Quo(getDenominationByUnit(unit), getDenominationByUnit("Wei"))
-	precI := new(big.Int)
-	precF.Int(precI)
-	prec := len(precI.String()) - 1
-
-	text := amountF.Text('f', prec)
-
-	if unit == "NEW" && strings.Contains(text, ".") {
-		for i := len(text) - 1; i > 0; i-- {
-			if text[i] != '0' {
-				if text[i] == '.' {
-					return text[:i]
-				}
-				return text[:i+1]
-			}
-		}
+	match := amountPattern.FindStringSubmatch(strings.TrimSpace(amountStr))
+	if match == nil || match[1]+match[2] == "" {
+		return nil, fmt.Errorf("invalid amount %q", amountStr)
+	}
+	integer, fraction := match[1], strings.TrimRight(match[2], "0")
+	if len(fraction) > decimals {
+		return nil, fmt.Errorf("amount %s has more than %d fractional digits in %s", amountStr, decimals, unit)
 	}
 
-	return text
+	amount, _ := new(big.Int).SetString(integer+fraction+strings.Repeat("0", decimals-len(fraction)), 10)
+	return amount, nil
 }
 
-func getAmountTextUnitByUnit(amount *big.Float, unit string) string {
-	amountF := amount.Quo(amount, getDenominationByUnit(unit))
-
-	precF := getDenominationByUnit(unit).Quo(getDenominationByUnit(unit), getDenominationByUnit("Wei"))
-	precI := new(big.Int)
-	precF.Int(precI)
-	prec := len(precI.String()) - 1
-
-	text := amountF.Text('f', prec)
+// formatWei returns the exact amount of wei in the unit without trailing zeros
+func formatWei(amount *big.Int, unit string) string {
+	decimals := unitDecimals[unit]
+	if decimals == 0 {
+		return amount.String()
+	}
 
-	if unit == "NEW" && strings.Contains(text, ".") {
-		for i := len(text) - 1; i > 0; i-- {
-			if text[i] != '0' {
-				if text[i] == '.' {
-					return text[:i]
-				}
-				return text[:i+1]
-			}
-		}
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
 	}
+	integer, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
 
+	text := integer
+	if fraction != "" {
+		text += "." + fraction
+	}
+	if amount.Sign() < 0 {
+		text = "-" + text
+	}
 	return text
 }
 
-func getWeiAmountTextByUnit(amount *big.Int, unit string) string {
-	amountF := new(big.Float)
-	amountF.SetInt(amount)
-
-	return getAmountTextByUnit(amountF, unit)
-}
-
-func getWeiAmountTextUnitByUnit1(amount *big.Int, unit string) string {
+// formatAmount returns the amount of wei in the unit followed by the unit,
+// in WEI or NEW by the amount if the unit is empty
+func formatAmount(amount *big.Int, unit string) string {
 	if unit == "" {
-		NEW1 := "1000000000000000000"
-		new1Big := new(big.Int)
-		new1Big.SetString(NEW1, 10)
-		if amount.Cmp(new1Big) < 0 {
-			// show in WEI
-			return amount.String() + " WEI"
-		}
-
 		unit = "NEW"
+		if new(big.Int).Abs(amount).Cmp(big.NewInt(params.Ether)) < 0 {
+			unit = "WEI"
+		}
 	}
-
-	amountF := new(big.Float)
-	amountF.SetInt(amount)
-
-	return getAmountTextByUnit(amountF, unit) + " " + unit
-
+	return formatWei(amount, unit) + " " + unit
 }
 
-func getWeiAmountTextUnitByUnit(amount *big.Int, unit string) string {
-	if unit == "" {
-		NEW1 := "1000000000000000000"
-		new1Big := new(big.Int)
-		new1Big.SetString(NEW1, 10)
-		if amount.Cmp(new1Big) < 0 {
-			// show in WEI
-			return amount.String() + " WEI"
-		}
-
-		unit = "NEW"
+// flagOrConfig returns the value of the flag if set, or the key of the config.
+// It is for the commands sharing the keys with the flags of `start`, as a key
+// can only be bound to one flag.
+func flagOrConfig(cmd *cobra.Command, flag, key string) string {
+	if f := cmd.Flags().Lookup(flag); f != nil && f.Changed {
+		return f.Value.String()
 	}
+	return viper.GetString(key)
+}
 
-	if unit == "WEI" {
-		return amount.String() + " WEI"
-	} else if unit == "NEW" {
-		const NEW_WEI = 18
-		amountStr := amount.String()
-		amountLen := len(amountStr)
-
-		if amountLen <= NEW_WEI {
-			for i := 0; i <= NEW_WEI+1-amountLen; i++ {
-				amountStr = "0" + amountStr
-			}
-			if len(amountStr) != NEW_WEI+1 {
-				return amount.String() + " WEI"
-			}
-			amountLen = NEW_WEI + 1
-		}
-		return amountStr[:amountLen-NEW_WEI] + "." + amountStr[amountLen-NEW_WEI:] + " NEW"
+// writeJSONFile writes v to path atomically by renaming a temp file
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
 	}
 
-	return amount.String() + " WEI"
-
+	return os.Rename(tmp.Name(), path)
 }
 
 func createNewAccount(walletPath string, numOfNew int) error {
 
-	wallet := keystore.NewKeyStore(walletPath,
-		keystore.LightScryptN, keystore.LightScryptP)
+	wallet := newKeyStore(walletPath)
 
 	walletPassword, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true)
 	if err != nil {
@@ -236,37 +189,3 @@ func createNewAccount(walletPath string, numOfNew int) error {
 
 	return nil
 }
-
-// TODO: add transaction receipt later by command `receipt`
-func showTransactionReceipt(url, txStr string) {
-	var jsonStr = []byte(fmt.Sprintf(`{"jsonrpc":"2.0","method":"eth_getTransactionReceipt","params":["%s"],"id":1}`, txStr))
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
-	req.Header.Set("Content-Type", "application/json")
-
-	clientHttp := &http.Client{}
-
-	resp, err := clientHttp.Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusOK {
-		var body json.RawMessage
-		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		bodyStr, err := json.MarshalIndent(body, "", "    ")
-		if err != nil {
-			fmt.Println("JSON marshaling failed: ", err)
-			return
-		}
-		fmt.Printf("%s\n", bodyStr)
-
-		return
-	}
-
-}
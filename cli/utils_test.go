@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestGetAmountWei(t *testing.T) {
+	tests := []struct {
+		amount string
+		unit   string
+		want   string // empty for error
+	}{
+		{"0.1", "NEW", "100000000000000000"},
+		{"16888", "NEW", "16888000000000000000000"},
+		{"1.000000000000000001", "NEW", "1000000000000000001"},
+		{"1.0000000000000000001", "NEW", ""},
+		{"1.50", "gwei", "1500000000"},
+		{".5", "FINNEY", "500000000000000"},
+		{"7", "WEI", "7"},
+		{"7.0", "WEI", "7"},
+		{"7.5", "WEI", ""},
+		{"-1", "NEW", ""},
+		{"1e18", "WEI", ""},
+		{".", "NEW", ""},
+		{"1", "ETH", ""},
+	}
+	for _, test := range tests {
+		got, err := getAmountWei(test.amount, test.unit)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("(%s %s) want error, got %v", test.amount, test.unit, got)
+			}
+			continue
+		}
+		if err != nil || got.String() != test.want {
+			t.Errorf("(%s %s) want %s, got %v %v", test.amount, test.unit, test.want, got, err)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	for wei, want := range map[int64]string{
+		0:                   "0 WEI",
+		999:                 "999 WEI",
+		1500000000000000000: "1.5 NEW",
+	} {
+		if got := formatAmount(big.NewInt(wei), ""); got != want {
+			t.Errorf("(%d) want %s, got %s", wei, want, got)
+		}
+	}
+}
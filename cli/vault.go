@@ -0,0 +1,437 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/spf13/viper"
+)
+
+// the values of `vault.mode`
+const (
+	vaultTransit = "transit" // the transit secrets engine
+	vaultPlugin  = "plugin"  // an Ethereum signing plugin, e.g. vault-plugin-secrets-ethsign
+)
+
+const defaultVaultAddress = "http://127.0.0.1:8200"
+
+// vaultConfig is the `vault` section of the config
+type vaultConfig struct {
+	Address      string
+	Mode         string
+	Mount        string // the mount path of the transit engine or the plugin
+	Key          string // the transit key, or the account of the plugin
+	Token        string
+	RoleID       string
+	SecretID     string
+	SecretIDFile string
+	AppRoleMount string
+}
+
+// vaultConfigFromViper returns the config, with the address and the token of
+// the env vars VAULT_ADDR and VAULT_TOKEN if not set
+func vaultConfigFromViper() vaultConfig {
+	c := vaultConfig{
+		Address:      viper.GetString("vault.address"),
+		Mode:         viper.GetString("vault.mode"),
+		Mount:        viper.GetString("vault.mount"),
+		Key:          viper.GetString("vault.key"),
+		Token:        viper.GetString("vault.token"),
+		RoleID:       viper.GetString("vault.roleID"),
+		SecretID:     viper.GetString("vault.secretID"),
+		SecretIDFile: viper.GetString("vault.secretIDFile"),
+		AppRoleMount: viper.GetString("vault.appRoleMount"),
+	}
+	if c.Address == "" {
+		c.Address = os.Getenv("VAULT_ADDR")
+	}
+	if c.Token == "" {
+		c.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if c.Mount == "" {
+		c.Mount = "transit"
+		if c.Mode == vaultPlugin {
+			c.Mount = "ethereum"
+		}
+	}
+	return c
+}
+
+// vaultSigner signs with a key in HashiCorp Vault, the key never leaves Vault
+type vaultSigner struct {
+	config  vaultConfig
+	client  *http.Client
+	address common.Address
+	pub     *ecdsa.PublicKey // the transit key
+
+	mu    sync.Mutex
+	token string
+	stop  chan struct{}
+}
+
+// vaultResponse is the envelope of the Vault API responses
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Auth   *vaultAuth      `json:"auth"`
+	Errors []string        `json:"errors"`
+}
+
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// newVaultSigner logs in to Vault with the token or AppRole, loads the
+// address of the key, and renews the token in the background
+func newVaultSigner(config vaultConfig) (*vaultSigner, error) {
+	if config.Key == "" {
+		return nil, fmt.Errorf("vault.key required")
+	}
+	if config.Address == "" {
+		config.Address = defaultVaultAddress
+	}
+	v := &vaultSigner{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+		stop:   make(chan struct{}),
+	}
+
+	auth, err := v.login()
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.Mode {
+	case vaultTransit:
+		err = v.loadTransitKey()
+	case vaultPlugin:
+		err = v.loadPluginAccount()
+	default:
+		err = fmt.Errorf("unknown vault.mode %q", config.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if auth.Renewable && auth.LeaseDuration > 0 {
+		go v.renewToken(time.Duration(auth.LeaseDuration) * time.Second)
+	}
+	return v, nil
+}
+
+// do calls the Vault API with the token
+func (v *vaultSigner) do(method, path string, in interface{}) (*vaultResponse, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(v.config.Address, "/")+"/v1/"+path, body)
+	if err != nil {
+		return nil, err
+	}
+	v.mu.Lock()
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	v.mu.Unlock()
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out vaultResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("%s %s: %v", method, path, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(out.Errors) > 0 {
+			return nil, fmt.Errorf("%s %s: %s", method, path, strings.Join(out.Errors, "; "))
+		}
+		return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	return &out, nil
+}
+
+// login logs in with AppRole if `vault.roleID` is set, or looks up the token
+func (v *vaultSigner) login() (*vaultAuth, error) {
+	if v.config.RoleID == "" {
+		if v.config.Token == "" {
+			return nil, fmt.Errorf("vault.token or vault.roleID required")
+		}
+		v.mu.Lock()
+		v.token = v.config.Token
+		v.mu.Unlock()
+
+		resp, err := v.do(http.MethodGet, "auth/token/lookup-self", nil)
+		if err != nil {
+			return nil, err
+		}
+		var data struct {
+			TTL       int64 `json:"ttl"`
+			Renewable bool  `json:"renewable"`
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			return nil, err
+		}
+		return &vaultAuth{ClientToken: v.config.Token, LeaseDuration: data.TTL, Renewable: data.Renewable}, nil
+	}
+
+	secretID := v.config.SecretID
+	if v.config.SecretIDFile != "" {
+		var err error
+		if secretID, err = readPasswordFile(v.config.SecretIDFile); err != nil {
+			return nil, err
+		}
+	}
+	mount := v.config.AppRoleMount
+	if mount == "" {
+		mount = "approle"
+	}
+	resp, err := v.do(http.MethodPost, "auth/"+mount+"/login", map[string]string{
+		"role_id":   v.config.RoleID,
+		"secret_id": secretID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("no token of the AppRole login")
+	}
+
+	v.mu.Lock()
+	v.token = resp.Auth.ClientToken
+	v.mu.Unlock()
+	return resp.Auth, nil
+}
+
+// renewToken renews the token at 2/3 of its TTL until Close
+func (v *vaultSigner) renewToken(ttl time.Duration) {
+	wait := ttl * 2 / 3
+	for {
+		select {
+		case <-v.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		auth, err := v.renew(ttl)
+		if err != nil {
+			log.Printf("renew Vault token error: %v", err)
+			wait = 30 * time.Second
+			continue
+		}
+		if !auth.Renewable || auth.LeaseDuration <= 0 {
+			return
+		}
+		ttl = time.Duration(auth.LeaseDuration) * time.Second
+		wait = ttl * 2 / 3
+	}
+}
+
+// renew renews the token. With AppRole it logs in again if the token can not
+// be renewed, or is renewed for less than half of the last TTL at its max TTL.
+func (v *vaultSigner) renew(ttl time.Duration) (*vaultAuth, error) {
+	resp, err := v.do(http.MethodPost, "auth/token/renew-self", map[string]interface{}{})
+	if err == nil && resp.Auth == nil {
+		err = fmt.Errorf("no auth of the renewal")
+	}
+	if v.config.RoleID == "" {
+		if err != nil {
+			return nil, err
+		}
+		return resp.Auth, nil
+	}
+	if err == nil && time.Duration(resp.Auth.LeaseDuration)*time.Second >= ttl/2 {
+		return resp.Auth, nil
+	}
+	return v.login()
+}
+
+// Close stops the renewal of the token
+func (v *vaultSigner) Close() {
+	close(v.stop)
+}
+
+func (v *vaultSigner) Address() common.Address {
+	return v.address
+}
+
+// loadTransitKey loads the public key of the latest version of the transit
+// key. Transit supports no secp256k1 key, so it signs only for NewChain,
+// which uses P-256.
+func (v *vaultSigner) loadTransitKey() error {
+	resp, err := v.do(http.MethodGet, v.config.Mount+"/keys/"+url.PathEscape(v.config.Key), nil)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Type          string          `json:"type"`
+		LatestVersion int             `json:"latest_version"`
+		Keys          json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return err
+	}
+	if data.Type != "ecdsa-p256" || !isNewton() {
+		return fmt.Errorf("transit key %s of type %s can not sign the transactions, use the vault.mode plugin", v.config.Key, data.Type)
+	}
+
+	var keys map[string]struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := json.Unmarshal(data.Keys, &keys); err != nil {
+		return err
+	}
+	block, _ := pem.Decode([]byte(keys[fmt.Sprint(data.LatestVersion)].PublicKey))
+	if block == nil {
+		return fmt.Errorf("no public key of transit key %s", v.config.Key)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	ecdsaPub, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("transit key %s is not an ECDSA key", v.config.Key)
+	}
+	v.pub = ecdsaPub
+	v.address = crypto.PubkeyToAddress(*ecdsaPub)
+
+	return nil
+}
+
+// signTransit signs the hash by the transit key
+func (v *vaultSigner) signTransit(hash []byte) (*big.Int, *big.Int, error) {
+	resp, err := v.do(http.MethodPost, v.config.Mount+"/sign/"+url.PathEscape(v.config.Key), map[string]interface{}{
+		"input":                base64.StdEncoding.EncodeToString(hash),
+		"prehashed":            true,
+		"hash_algorithm":       "sha2-256",
+		"marshaling_algorithm": "asn1",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var data struct {
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, nil, err
+	}
+	return parseTransitSignature(data.Signature)
+}
+
+// parseTransitSignature parses the ASN.1 signature vault:v<version>:<base64>
+func parseTransitSignature(signature string) (*big.Int, *big.Int, error) {
+	parts := strings.SplitN(signature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, nil, fmt.Errorf("invalid transit signature %q", signature)
+	}
+	der, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid transit signature: %v", err)
+	}
+	var sig struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) != 0 {
+		return nil, nil, fmt.Errorf("invalid transit signature: %v", err)
+	}
+	return sig.R, sig.S, nil
+}
+
+// loadPluginAccount loads the address of the account in the plugin
+func (v *vaultSigner) loadPluginAccount() error {
+	resp, err := v.do(http.MethodGet, v.config.Mount+"/accounts/"+url.PathEscape(v.config.Key), nil)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return err
+	}
+	if !common.IsHexAddress(data.Address) {
+		return fmt.Errorf("invalid address %q of account %s", data.Address, v.config.Key)
+	}
+	v.address = common.HexToAddress(data.Address)
+
+	return nil
+}
+
+// signPlugin signs the transaction by the plugin, which returns the signed
+// transaction in RLP
+func (v *vaultSigner) signPlugin(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	resp, err := v.do(http.MethodPost, v.config.Mount+"/accounts/"+url.PathEscape(v.config.Key)+"/sign", map[string]interface{}{
+		"to":       tx.To().Hex(),
+		"value":    tx.Value().String(),
+		"gas":      tx.Gas(),
+		"gasPrice": tx.GasPrice().String(),
+		"nonce":    fmt.Sprint(tx.Nonce()),
+		"chainId":  chainID.String(),
+		"data":     hexutil.Encode(tx.Data()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var data struct {
+		SignedTransaction string `json:"signed_transaction"`
+	}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, err
+	}
+	raw, err := hexutil.Decode(data.SignedTransaction)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signed transaction: %v", err)
+	}
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(raw, signed); err != nil {
+		return nil, fmt.Errorf("invalid signed transaction: %v", err)
+	}
+	return signed, nil
+}
+
+func (v *vaultSigner) SignTx(from common.Address, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if from != v.address {
+		return nil, fmt.Errorf("%s is not the Vault key %s", from.Hex(), v.address.Hex())
+	}
+	if v.config.Mode == vaultTransit {
+		return signTxWith(tx, chainID, v.pub, v.signTransit)
+	}
+
+	signed, err := v.signPlugin(tx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSignedTx(from, tx, signed, chainID); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
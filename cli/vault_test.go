@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// fakeVault serves the AppRole, token, transit and ethsign plugin APIs used
+// by vaultSigner
+type fakeVault struct {
+	key      *ecdsa.PrivateKey // the key of the plugin account
+	transit  *ecdsa.PrivateKey // the ecdsa-p256 transit key
+	renewals int32
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(code int, v interface{}) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			reply(http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{"auth": vaultAuth{ClientToken: "s.approle", LeaseDuration: 1, Renewable: true}})
+		return
+	}
+	if token := r.Header.Get("X-Vault-Token"); token != "s.approle" && token != "s.root" {
+		reply(http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "GET /v1/auth/token/lookup-self":
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"ttl": 0, "renewable": false}})
+	case "POST /v1/auth/token/renew-self":
+		atomic.AddInt32(&f.renewals, 1)
+		reply(http.StatusOK, map[string]interface{}{"auth": vaultAuth{ClientToken: "s.approle", LeaseDuration: 1, Renewable: true}})
+	case "GET /v1/ethereum/accounts/faucet":
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]string{"address": crypto.PubkeyToAddress(f.key.PublicKey).Hex()}})
+	case "POST /v1/ethereum/accounts/faucet/sign":
+		value, _ := new(big.Int).SetString(body["value"].(string), 10)
+		gasPrice, _ := new(big.Int).SetString(body["gasPrice"].(string), 10)
+		chainID, _ := new(big.Int).SetString(body["chainId"].(string), 10)
+		nonce, _ := strconv.ParseUint(body["nonce"].(string), 10, 64)
+		tx := types.NewTransaction(nonce, common.HexToAddress(body["to"].(string)), value, uint64(body["gas"].(float64)), gasPrice, nil)
+		signed, _ := types.SignTx(tx, types.NewEIP155Signer(chainID), f.key)
+		raw, _ := rlp.EncodeToBytes(signed)
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]string{"signed_transaction": hexutil.Encode(raw)}})
+	case "GET /v1/transit/keys/faucet":
+		der, _ := x509.MarshalPKIXPublicKey(&f.transit.PublicKey)
+		pub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"type":           "ecdsa-p256",
+			"latest_version": 1,
+			"keys":           map[string]interface{}{"1": map[string]string{"public_key": string(pub)}},
+		}})
+	case "POST /v1/transit/sign/faucet":
+		hash, _ := base64.StdEncoding.DecodeString(body["input"].(string))
+		sig, _ := ecdsa.SignASN1(rand.Reader, f.transit, hash)
+		reply(http.StatusOK, map[string]interface{}{"data": map[string]string{"signature": "vault:v1:" + base64.StdEncoding.EncodeToString(sig)}})
+	default:
+		reply(http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func TestVaultSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	transit, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	vault := &fakeVault{key: key, transit: transit}
+	ts := httptest.NewServer(vault)
+	defer ts.Close()
+
+	chainID := big.NewInt(1007)
+	tx := types.NewTransaction(3, common.HexToAddress("0x8709Fe1cB55C6aB630456C887af578e7bE9F7490"), big.NewInt(1e18), 21000, big.NewInt(1e9), nil)
+
+	// the plugin with AppRole, the token renewed in the background
+	v, err := newVaultSigner(vaultConfig{Address: ts.URL, Mode: vaultPlugin, Mount: "ethereum", Key: "faucet", RoleID: "role", SecretID: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	if v.Address() != from {
+		t.Errorf("address %s, want %s", v.Address().Hex(), from.Hex())
+	}
+	signed, err := v.SignTx(from, tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender, _ := types.Sender(types.NewEIP155Signer(chainID), signed); sender != from {
+		t.Errorf("sender %s, want %s", sender.Hex(), from.Hex())
+	}
+	for deadline := time.Now().Add(3 * time.Second); atomic.LoadInt32(&vault.renewals) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("token not renewed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := newVaultSigner(vaultConfig{Address: ts.URL, Mode: vaultPlugin, Mount: "ethereum", Key: "faucet", RoleID: "role", SecretID: "wrong"}); err == nil {
+		t.Error("wrong secret ID accepted")
+	}
+
+	// transit with the token signs only with P-256 of NewChain
+	v, err = newVaultSigner(vaultConfig{Address: ts.URL, Mode: vaultTransit, Mount: "transit", Key: "faucet", Token: "s.root"})
+	if !isNewton() {
+		if err == nil {
+			t.Error("P-256 transit key accepted for Ethereum")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	from = crypto.PubkeyToAddress(transit.PublicKey)
+	signed, err = v.SignTx(from, tx, chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender, _ := types.Sender(types.NewEIP155Signer(chainID), signed); sender != from {
+		t.Errorf("sender %s, want %s", sender.Hex(), from.Hex())
+	}
+}
+
+func TestParseTransitSignature(t *testing.T) {
+	if _, _, err := parseTransitSignature("vault:v1:MEUCIQ"); err == nil {
+		t.Error("invalid signature accepted")
+	}
+	if _, _, err := parseTransitSignature("MEUCIQ"); err == nil {
+		t.Error("signature without version accepted")
+	}
+}
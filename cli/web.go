@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/spf13/viper"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// webPage is the data of the web UI template
+type webPage struct {
+	Title    string
+	Logo     string
+	Explorer string
+	Version  string
+	Lang     string
+	Messages messages
+}
+
+// loadWebTemplate returns the template of web.template if set,
+// or the embedded one otherwise.
+func loadWebTemplate() (*template.Template, error) {
+	if path := viper.GetString("web.template"); path != "" {
+		return template.ParseFiles(path)
+	}
+	return template.ParseFS(webFS, "web/index.html")
+}
+
+func (cli *CLI) registerWebUI(mux *http.ServeMux) error {
+	tmpl, err := loadWebTemplate()
+	if err != nil {
+		return err
+	}
+	static, err := fs.Sub(webFS, "web/static")
+	if err != nil {
+		return err
+	}
+
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		p := cli.printer(r)
+		page := webPage{
+			Title:    viper.GetString("web.title"),
+			Logo:     viper.GetString("web.logo"),
+			Explorer: viper.GetString("web.explorer"),
+			Version:  cli.version,
+			Lang:     p.lang,
+			Messages: p.Messages(),
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, page); err != nil {
+			log.Printf("web template error: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+
+	return nil
+}
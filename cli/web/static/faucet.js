@@ -0,0 +1,267 @@
+(function () {
+  "use strict";
+
+  var BASE58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz";
+  var POLL_INTERVAL = 3000;
+
+  var config = window.FAUCET || {};
+  var info = null;
+
+  // t formats the message id of the catalog with the Go style verbs replaced by args.
+  function t(id) {
+    var args = Array.prototype.slice.call(arguments, 1);
+    var text = (config.messages && config.messages[id]) || id;
+    return text.replace(/%[vsd]/g, function () {
+      return args.length ? String(args.shift()) : "";
+    });
+  }
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function setStatus(text, state) {
+    $("progress").hidden = false;
+    $("status").textContent = text;
+    $("status").className = "status " + (state || "");
+  }
+
+  function toHex(bytes) {
+    return Array.prototype.map.call(bytes, function (b) {
+      return ("0" + b.toString(16)).slice(-2);
+    }).join("");
+  }
+
+  function sha256(bytes) {
+    return crypto.subtle.digest("SHA-256", bytes).then(function (h) {
+      return new Uint8Array(h);
+    });
+  }
+
+  function base58Decode(s) {
+    var x = BigInt(0);
+    for (var i = 0; i < s.length; i++) {
+      var v = BASE58.indexOf(s[i]);
+      if (v < 0) {
+        return null;
+      }
+      x = x * BigInt(58) + BigInt(v);
+    }
+    var hex = x === BigInt(0) ? "" : x.toString(16);
+    if (hex.length % 2) {
+      hex = "0" + hex;
+    }
+    var zeros = 0;
+    while (zeros < s.length && s[zeros] === BASE58[0]) {
+      zeros++;
+    }
+    var out = new Uint8Array(zeros + hex.length / 2);
+    for (var j = 0; j < hex.length / 2; j++) {
+      out[zeros + j] = parseInt(hex.substr(j * 2, 2), 16);
+    }
+    return out;
+  }
+
+  // parseAddress resolves a hex or NEW format address to the lower case
+  // hex address, or rejects with a message for the user.
+  function parseAddress(s) {
+    s = s.trim();
+    if (/^(0x|0X)?[0-9a-fA-F]{40}$/.test(s)) {
+      return Promise.resolve("0x" + s.replace(/^0x/i, "").toLowerCase());
+    }
+    if (s.indexOf("NEW") !== 0) {
+      return Promise.reject(new Error(t("web.invalidAddress")));
+    }
+
+    var raw = base58Decode(s.slice(3));
+    if (!raw || raw.length < 1 + 1 + 20 + 4) {
+      return Promise.reject(new Error(t("web.invalidNewAddress")));
+    }
+    var payload = raw.slice(0, raw.length - 4);
+    return sha256(payload).then(sha256).then(function (sum) {
+      for (var i = 0; i < 4; i++) {
+        if (sum[i] !== raw[payload.length + i]) {
+          throw new Error(t("web.checksumError"));
+        }
+      }
+      if (payload[0] !== 0) {
+        throw new Error(t("web.invalidNewAddress"));
+      }
+      var body = payload.slice(1);
+      var chainID = BigInt("0x" + (toHex(body.slice(0, body.length - 20)) || "0"));
+      if (info && chainID.toString() !== info.chainId) {
+        throw new Error(t("web.chainMismatch", chainID, info.chainId));
+      }
+      return "0x" + toHex(body.slice(body.length - 20));
+    });
+  }
+
+  function leadingZeroBits(bytes) {
+    var n = 0;
+    for (var i = 0; i < bytes.length; i++) {
+      if (bytes[i] === 0) {
+        n += 8;
+        continue;
+      }
+      return n + Math.clz32(bytes[i]) - 24;
+    }
+    return n;
+  }
+
+  // solvePoW finds the proof of work the server asks for in /api/v1/info.
+  function solvePoW(hexAddress) {
+    if (!info || !info.powDifficulty) {
+      return Promise.resolve("");
+    }
+    var encoder = new TextEncoder();
+    var prefix = info.powSalt + ":" + hexAddress + ":";
+    var nonce = 0;
+
+    setStatus(t("web.solving"));
+    function next() {
+      var pow = String(nonce++);
+      return sha256(encoder.encode(prefix + pow)).then(function (h) {
+        if (leadingZeroBits(h) >= info.powDifficulty) {
+          return pow;
+        }
+        return next();
+      });
+    }
+    return next();
+  }
+
+  function showTx(hash) {
+    var tx = $("tx");
+    tx.textContent = "";
+    if (config.explorer) {
+      var a = document.createElement("a");
+      a.href = config.explorer.replace(/\/$/, "") + "/tx/" + hash;
+      a.target = "_blank";
+      a.rel = "noopener";
+      a.textContent = hash;
+      tx.appendChild(a);
+    } else {
+      tx.textContent = hash;
+    }
+  }
+
+  // showEvent shows the progress event of the ticket, and returns true
+  // if the request is finished.
+  function showEvent(e) {
+    if (e.txHash) {
+      showTx(e.txHash);
+    }
+    switch (e.type) {
+      case "queued":
+        setStatus(t("web.queued"));
+        return false;
+      case "broadcast":
+        setStatus(t("web.pending"));
+        return false;
+      case "included":
+        setStatus(t("web.included", e.blockNumber));
+        return false;
+      case "confirmation":
+        setStatus(t("web.confirming", e.blockNumber, e.confirmations, e.required));
+        return false;
+      case "confirmed":
+        setStatus(t("web.mined", e.blockNumber), "mined");
+        return true;
+      case "failed":
+        setStatus(e.blockNumber ? t("web.failed", e.blockNumber) : e.error, "failed");
+        return true;
+    }
+    return false;
+  }
+
+  // poll follows the ticket by the status API if EventSource is not available.
+  function poll(id) {
+    fetch("/api/v1/requests/" + id + "?lang=" + encodeURIComponent(config.lang)).then(function (resp) {
+      return resp.json();
+    }).then(function (ticket) {
+      var types = { queued: "queued", sent: "broadcast", mined: "confirmed", failed: "failed" };
+      var done = showEvent({
+        type: types[ticket.status],
+        txHash: ticket.txHash,
+        blockNumber: ticket.blockNumber,
+        error: ticket.error
+      });
+      if (!done) {
+        setTimeout(function () { poll(id); }, POLL_INTERVAL);
+      }
+    }).catch(function () {
+      setTimeout(function () { poll(id); }, POLL_INTERVAL);
+    });
+  }
+
+  function follow(id) {
+    if (!window.EventSource) {
+      poll(id);
+      return;
+    }
+    var source = new EventSource("/api/v1/events?ticket=" + id + "&lang=" + encodeURIComponent(config.lang));
+    ["validated", "queued", "broadcast", "included", "confirmation", "confirmed", "failed"].forEach(function (type) {
+      source.addEventListener(type, function (msg) {
+        if (showEvent(JSON.parse(msg.data))) {
+          source.close();
+        }
+      });
+    });
+  }
+
+  function submit(event) {
+    event.preventDefault();
+    var address = $("address").value.trim();
+    $("address-error").textContent = "";
+    $("submit").disabled = true;
+
+    parseAddress(address).then(function (hexAddress) {
+      return solvePoW(hexAddress);
+    }).then(function (pow) {
+      setStatus(t("web.sending"));
+      return fetch("/api/v1/requests?lang=" + encodeURIComponent(config.lang), {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ address: address, pow: pow })
+      });
+    }).then(function (resp) {
+      return resp.json();
+    }).then(function (result) {
+      if (result.error) {
+        throw new Error(result.error);
+      }
+      showEvent({ type: result.status });
+      follow(result.id);
+    }).catch(function (err) {
+      $("progress").hidden = true;
+      $("address-error").textContent = err.message;
+    }).then(function () {
+      $("submit").disabled = false;
+    });
+  }
+
+  function validate() {
+    var address = $("address").value.trim();
+    if (address === "") {
+      $("address-error").textContent = "";
+      return;
+    }
+    parseAddress(address).then(function () {
+      $("address-error").textContent = "";
+    }, function (err) {
+      $("address-error").textContent = err.message;
+    });
+  }
+
+  fetch("/api/v1/info").then(function (resp) {
+    return resp.json();
+  }).then(function (i) {
+    info = i;
+    $("summary").textContent = i.cooldownSeconds ?
+      t("web.summaryCooldown", i.amount, i.unit, i.cooldown) :
+      t("web.summary", i.amount, i.unit);
+  });
+
+  $("faucet-form").addEventListener("submit", submit);
+  $("address").addEventListener("input", validate);
+})();
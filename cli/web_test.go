@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWebTemplate(t *testing.T) {
+	tmpl, err := loadWebTemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	page := webPage{Title: "Test Faucet", Explorer: "https://explorer.example.com", Lang: "en", Messages: defaultMessages}
+	if err := tmpl.Execute(&buf, page); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `explorer: "https://explorer.example.com"`) {
+		t.Errorf("explorer not rendered: %s", buf.String())
+	}
+}
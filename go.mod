@@ -4,9 +4,12 @@ go 1.22.12
 
 require (
 	github.com/ethereum/go-ethereum v1.13.15
+	github.com/gorilla/websocket v1.4.2
+	github.com/miekg/pkcs11 v1.1.1
 	github.com/sirupsen/logrus v1.9.0
 	github.com/spf13/cobra v1.5.0
 	github.com/spf13/viper v1.7.0
+	github.com/tyler-smith/go-bip39 v1.1.0
 )
 
 require (
@@ -23,7 +26,6 @@ require (
 	github.com/fsnotify/fsnotify v1.6.0 // indirect
 	github.com/go-ole/go-ole v1.3.0 // indirect
 	github.com/google/uuid v1.3.0 // indirect
-	github.com/gorilla/websocket v1.4.2 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/holiman/uint256 v1.2.4 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect